@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bsonDocument converts a parsed document to a bson.D for the driver to encode.  Its
+// keys are sorted, so that the encoding doesn't depend on the order of the map, and
+// are rewritten by key unless it's nil.
+func bsonDocument(doc map[string]interface{}, key func(string) string) (bson.D, error) {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	d := make(bson.D, 0, len(doc))
+	for _, k := range keys {
+		value, err := bsonValue(doc[k], key)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			k = key(k)
+		}
+		d = append(d, bson.E{Key: k, Value: value})
+	}
+	return d, nil
+}
+
+// bsonValue converts a value of a parsed document to the type the driver encodes as
+// its BSON type.  Numbers the parser read as float64 are integers when they have no
+// fractional part, and int32s when they fit.
+func bsonValue(value interface{}, key func(string) string) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string, time.Time, int32, primitive.ObjectID:
+		return v, nil
+	case float64:
+		if n, ok := integral(v); ok {
+			return bsonValue(n, key)
+		}
+		return v, nil
+	case int:
+		return bsonValue(int64(v), key)
+	case int64:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int32(v), nil
+		}
+		return v, nil
+	case parser.ObjectID:
+		oid, err := primitive.ObjectIDFromHex(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("bson: invalid ObjectId '%s'", string(v))
+		}
+		return oid, nil
+	case map[string]interface{}:
+		return bsonDocument(v, key)
+	case []interface{}:
+		a := make(bson.A, len(v))
+		for i, elem := range v {
+			var err error
+			if a[i], err = bsonValue(elem, key); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("bson: unsupported type %T", value)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// A filter is an expression over the fields of a parsed line, such as
+//
+//	duration>100 && namespace=~"^app\."
+//	severity in (warning,error)
+//	component=REPL
+//
+// Comparisons are = (or ==), !=, <, <=, >, >=, =~ and !~ (regular expression match),
+// and in, which is true if the field equals any of a list of values.  They combine
+// with &&, ||, ! and parentheses.  A field on its own is true if it's present and
+// not false, zero or empty.  Nested fields are named with dots, as in
+// command.find.
+//
+// A value is a number, a word or a double quoted string.  Numbers compare
+// numerically with numeric fields, and everything else compares as strings.  A
+// comparison with a missing field is false, except for != and !~, which are its
+// negation.
+type filter struct {
+	expr filterExpr
+}
+
+// compileFilter parses a filter expression
+func compileFilter(s string) (*filter, error) {
+	p := &filterParser{input: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected '%s'", tok.text)
+	}
+	return &filter{expr: expr}, nil
+}
+
+func (f *filter) match(entry *parser.LogEntry) bool {
+	return f.expr.eval(entry.Fields)
+}
+
+type filterExpr interface {
+	eval(fields map[string]interface{}) bool
+}
+
+type andExpr struct {
+	left, right filterExpr
+}
+
+func (e *andExpr) eval(fields map[string]interface{}) bool {
+	return e.left.eval(fields) && e.right.eval(fields)
+}
+
+type orExpr struct {
+	left, right filterExpr
+}
+
+func (e *orExpr) eval(fields map[string]interface{}) bool {
+	return e.left.eval(fields) || e.right.eval(fields)
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+func (e *notExpr) eval(fields map[string]interface{}) bool {
+	return !e.expr.eval(fields)
+}
+
+// truthyExpr is a field on its own
+type truthyExpr struct {
+	field string
+}
+
+func (e *truthyExpr) eval(fields map[string]interface{}) bool {
+	value, ok := lookupField(fields, e.field)
+	if !ok {
+		return false
+	}
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if n, ok := filterNumber(value); ok {
+		return n != 0
+	}
+	return true
+}
+
+// filterValue is a value in a filter expression
+type filterValue struct {
+	s     string
+	n     float64
+	isNum bool
+}
+
+type compareExpr struct {
+	field string
+	op    string
+	value filterValue
+	re    *regexp.Regexp
+}
+
+func (e *compareExpr) eval(fields map[string]interface{}) bool {
+	switch e.op {
+	case "!=":
+		return !(&compareExpr{field: e.field, op: "=", value: e.value}).eval(fields)
+	case "!~":
+		return !(&compareExpr{field: e.field, op: "=~", re: e.re}).eval(fields)
+	}
+
+	value, ok := lookupField(fields, e.field)
+	if !ok {
+		return false
+	}
+	if e.op == "=~" {
+		s, ok := filterString(value)
+		return ok && e.re.MatchString(s)
+	}
+
+	c, ok := compareValue(value, e.value)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "=":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type inExpr struct {
+	field  string
+	values []filterValue
+}
+
+func (e *inExpr) eval(fields map[string]interface{}) bool {
+	value, ok := lookupField(fields, e.field)
+	if !ok {
+		return false
+	}
+	for _, v := range e.values {
+		if c, ok := compareValue(value, v); ok && c == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField finds a field, following dots into nested documents
+func lookupField(fields map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	doc := fields
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		value, ok := doc[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return value, true
+		}
+		if doc, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// compareValue compares a field's value with v, numerically if they're both numbers
+func compareValue(value interface{}, v filterValue) (int, bool) {
+	if v.isNum {
+		if n, ok := filterNumber(value); ok {
+			switch {
+			case n < v.n:
+				return -1, true
+			case n > v.n:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	s, ok := filterString(value)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(s, v.s), true
+}
+
+func filterNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func filterString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case parser.ObjectID:
+		return string(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	if n, ok := filterNumber(value); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// the kinds of token in a filter expression
+const (
+	tokEOF = iota
+	tokWord
+	tokString
+	tokOp
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+type filterParser struct {
+	input  string
+	tokens []filterToken
+}
+
+// filterOps are the operators and punctuation, longest first so that "<=" isn't
+// read as "<"
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "=", "<", ">", "!", "(", ")", ","}
+
+func isFilterWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("_.$@:+-", c) >= 0
+}
+
+func (p *filterParser) lex() error {
+	s := p.input
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i == len(s) {
+					return fmt.Errorf("filter: unterminated string at offset %d", start)
+				}
+				if s[i] == '"' {
+					break
+				}
+				// \" and \\ are escapes, and any other backslash is kept so that
+				// regular expressions can be written naturally
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			i++
+			p.tokens = append(p.tokens, filterToken{tokString, sb.String(), start})
+		case isFilterWordByte(c):
+			start := i
+			for i < len(s) && isFilterWordByte(s[i]) {
+				i++
+			}
+			p.tokens = append(p.tokens, filterToken{tokWord, s[start:i], start})
+		default:
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("filter: unexpected '%c' at offset %d", c, i)
+			}
+			p.tokens = append(p.tokens, filterToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, filterToken{tokEOF, "end of filter", len(s)})
+	return nil
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[0]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[0]
+	if tok.kind != tokEOF {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *filterParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == op
+}
+
+func (p *filterParser) errorf(tok filterToken, format string, args ...interface{}) error {
+	return fmt.Errorf("filter: %s at offset %d", fmt.Sprintf(format, args...), tok.pos)
+}
+
+func (p *filterParser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return p.errorf(tok, "expected '%s' but found '%s'", op, tok.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	if p.isOp("!") {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr}, nil
+	}
+	if p.isOp("(") {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterExpr, error) {
+	tok := p.next()
+	if tok.kind != tokWord {
+		return nil, p.errorf(tok, "expected a field name but found '%s'", tok.text)
+	}
+	field := tok.text
+
+	op := p.peek()
+	switch {
+	case op.kind == tokWord && op.text == "in":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var values []filterValue
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		return &inExpr{field, values}, p.expect(")")
+
+	case op.kind == tokOp:
+		switch op.text {
+		case "=", "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		default:
+			return &truthyExpr{field}, nil
+		}
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		expr := &compareExpr{field: field, op: op.text, value: value}
+		if op.text == "==" {
+			expr.op = "="
+		}
+		if op.text == "=~" || op.text == "!~" {
+			if expr.re, err = regexp.Compile(value.s); err != nil {
+				return nil, p.errorf(op, "bad regular expression: %v", err)
+			}
+		}
+		return expr, nil
+	}
+	return &truthyExpr{field}, nil
+}
+
+func (p *filterParser) parseValue() (filterValue, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return filterValue{s: tok.text}, nil
+	case tokWord:
+		v := filterValue{s: tok.text}
+		if n, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			v.n, v.isNum = n, true
+		}
+		return v, nil
+	}
+	return filterValue{}, p.errorf(tok, "expected a value but found '%s'", tok.text)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestFilter(t *testing.T) {
+	entry := &parser.LogEntry{Fields: map[string]interface{}{
+		"severity":    "warning",
+		"component":   "REPL",
+		"namespace":   "app.users",
+		"duration":    float64(102),
+		"nreturned":   float64(0),
+		"operation":   "command",
+		"command":     map[string]interface{}{"find": "users", "limit": float64(1)},
+		"collscan":    true,
+		"query_shape": `{"a":1}`,
+	}}
+	cases := []struct {
+		filter   string
+		expected bool
+	}{
+		{`duration>100 && namespace=~"^app\."`, true},
+		{`duration>200 && namespace=~"^app\."`, false},
+		{`duration>=102 && duration<=102 && duration==102`, true},
+		{`severity in (warning,error)`, true},
+		{`severity in (error, fatal)`, false},
+		{`component=REPL`, true},
+		{`component=repl`, false},
+		{`component!=REPL || namespace="app.users"`, true},
+		{`!(component=REPL)`, false},
+		{`command.find=users && command.limit<2`, true},
+		{`command.sort`, false},
+		{`collscan && !nreturned`, true},
+		{`missing=1`, false},
+		{`missing!=1`, true},
+		{`namespace!~"^admin\."`, true},
+		{`query_shape="{\"a\":1}"`, true},
+		// numbers compare as strings with string fields
+		{`namespace>1`, true},
+		{`duration>"2"`, false},
+	}
+	for i, c := range cases {
+		f, err := compileFilter(c.filter)
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+			continue
+		}
+		if got := f.match(entry); got != c.expected {
+			t.Errorf("case %d: expected %s to be %v", i, c.filter, c.expected)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	cases := []struct {
+		filter   string
+		expected string
+	}{
+		{`duration>`, `filter: expected a value but found 'end of filter' at offset 9`},
+		{`(duration>1`, `filter: expected ')' but found 'end of filter' at offset 11`},
+		{`duration>1 namespace=a`, `filter: unexpected 'namespace' at offset 11`},
+		{`namespace=~"("`, `filter: bad regular expression: error parsing regexp: missing closing ): ` + "`(`" + ` at offset 9`},
+		{`namespace="app`, `filter: unterminated string at offset 10`},
+		{`severity in warning`, `filter: expected '(' but found 'warning' at offset 12`},
+		{`duration # 1`, `filter: unexpected '#' at offset 9`},
+	}
+	for i, c := range cases {
+		_, err := compileFilter(c.filter)
+		if err == nil || err.Error() != c.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%v'", i, c.expected, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+)
+
+// Encoder writes parsed log lines to an output in some format
+type Encoder interface {
+	Encode(doc map[string]interface{}) error
+	// Flush writes out anything the Encoder buffered
+	Flush() error
+}
+
+// InitFormat returns an Encoder writing to w.  columns is the list of fields
+// selected with -columns, which only some formats use.
+type InitFormat func(w io.Writer, columns []string) (Encoder, error)
+
+type formatRegistry map[string]InitFormat
+
+var (
+	ErrFormatAlreadyRegistered = errors.New("format: already registered")
+	ErrFormatNotRegistered     = errors.New("format: not registered")
+)
+
+var formats formatRegistry
+
+func init() {
+	formats = formatRegistry(map[string]InitFormat{})
+}
+
+func RegisterFormat(name string, initFn InitFormat) error {
+	if _, ok := formats[name]; ok {
+		return ErrFormatAlreadyRegistered
+	}
+	formats[name] = initFn
+	return nil
+}
+
+func GetFormat(name string, w io.Writer, columns []string) (Encoder, error) {
+	fn, ok := formats[name]
+	if !ok {
+		return nil, ErrFormatNotRegistered
+	}
+	return fn(w, columns)
+}
+
+// jsonEncoder writes newline delimited JSON
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *jsonEncoder) Encode(doc map[string]interface{}) error {
+	return e.enc.Encode(doc)
+}
+
+func (e *jsonEncoder) Flush() error {
+	return nil
+}
+
+func init() {
+	RegisterFormat("json", func(w io.Writer, columns []string) (Encoder, error) {
+		return newJSONEncoder(w), nil
+	})
+}
+
+// integral returns the value of a number the parser read as a float64 if it has no
+// fractional part, so that it can be written as an integer
+func integral(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bsonEncoder writes raw BSON documents back to back, the format mongorestore and
+// bsondump read
+type bsonEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (e *bsonEncoder) Encode(doc map[string]interface{}) error {
+	d, err := bsonDocument(doc, nil)
+	if err != nil {
+		return err
+	}
+	if e.buf, err = bson.MarshalAppend(e.buf[:0], d); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+func (e *bsonEncoder) Flush() error {
+	return nil
+}
+
+func init() {
+	RegisterFormat("bson", func(w io.Writer, columns []string) (Encoder, error) {
+		return &bsonEncoder{w: w}, nil
+	})
+}
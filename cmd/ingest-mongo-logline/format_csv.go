@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+var ErrNoColumns = errors.New("format: -columns is required for csv and tsv")
+
+// csvEncoder writes the selected columns of every line as CSV (or TSV), with a
+// header row.  Documents and lists are written as JSON, missing fields as empty.
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+	header  bool
+	record  []string
+}
+
+func newCSVEncoder(w io.Writer, columns []string, comma rune) (Encoder, error) {
+	if len(columns) == 0 {
+		return nil, ErrNoColumns
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvEncoder{w: cw, columns: columns, record: make([]string, len(columns))}, nil
+}
+
+func (e *csvEncoder) Encode(doc map[string]interface{}) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(e.columns); err != nil {
+			return err
+		}
+	}
+	for i, column := range e.columns {
+		value, err := csvValue(doc[column])
+		if err != nil {
+			return err
+		}
+		e.record[i] = value
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func init() {
+	RegisterFormat("csv", func(w io.Writer, columns []string) (Encoder, error) {
+		return newCSVEncoder(w, columns, ',')
+	})
+	RegisterFormat("tsv", func(w io.Writer, columns []string) (Encoder, error) {
+		return newCSVEncoder(w, columns, '\t')
+	})
+}
+
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case parser.ObjectID:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	buf, err := json.Marshal(value)
+	return string(buf), err
+}
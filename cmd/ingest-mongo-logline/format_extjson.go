@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	mongo_json "github.com/mongodb/mongo-tools/common/json"
+	"github.com/toshok/mongologtools/parser"
+)
+
+// extJSONEncoder writes newline delimited canonical MongoDB Extended JSON v2, which
+// keeps the BSON types of ObjectIds, dates and numbers
+type extJSONEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *extJSONEncoder) Encode(doc map[string]interface{}) error {
+	v, err := toExtJSON(doc)
+	if err != nil {
+		return err
+	}
+	return e.enc.Encode(v)
+}
+
+func (e *extJSONEncoder) Flush() error {
+	return nil
+}
+
+func init() {
+	RegisterFormat("extjson", func(w io.Writer, columns []string) (Encoder, error) {
+		return &extJSONEncoder{enc: json.NewEncoder(w)}, nil
+	})
+}
+
+func toExtJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool:
+		return v, nil
+	case string:
+		return v, nil
+	case float64:
+		if n, ok := integral(v); ok {
+			return extJSONInt(n), nil
+		}
+		return map[string]string{"$numberDouble": extJSONDouble(v)}, nil
+	case int:
+		return extJSONInt(int64(v)), nil
+	case int64:
+		return extJSONInt(v), nil
+	case time.Time:
+		ms := v.UnixNano() / int64(time.Millisecond)
+		return map[string]interface{}{"$date": map[string]string{"$numberLong": strconv.FormatInt(ms, 10)}}, nil
+	case parser.ObjectID:
+		return map[string]string{"$oid": string(v)}, nil
+	case mongo_json.ObjectId:
+		return map[string]string{"$oid": string(v)}, nil
+	case map[string]interface{}:
+		doc := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			var err error
+			if doc[key], err = toExtJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+		return doc, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			var err error
+			if list[i], err = toExtJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("extjson: unsupported type %T", value)
+}
+
+func extJSONInt(n int64) interface{} {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return map[string]string{"$numberInt": strconv.FormatInt(n, 10)}
+	}
+	return map[string]string{"$numberLong": strconv.FormatInt(n, 10)}
+}
+
+func extJSONDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestFormats(t *testing.T) {
+	doc := map[string]interface{}{
+		"namespace": "test.foo",
+		"duration":  float64(102),
+		"ratio":     1.5,
+		"query": map[string]interface{}{
+			"_id":  parser.ObjectID("54e792daf1845f045f4c000e"),
+			"when": time.Unix(1412941647, 719000000),
+			"n":    float64(1 << 40),
+			"tags": []interface{}{"a", nil, true},
+		},
+	}
+	cases := []struct {
+		format   string
+		columns  []string
+		expected string
+	}{
+		{
+			"extjson", nil,
+			`{"duration":{"$numberInt":"102"},"namespace":"test.foo","query":{"_id":{"$oid":"54e792daf1845f045f4c000e"},"n":{"$numberLong":"1099511627776"},"tags":["a",null,true],"when":{"$date":{"$numberLong":"1412941647719"}}},"ratio":{"$numberDouble":"1.5"}}` + "\n",
+		},
+		{
+			"json", nil,
+			`{"duration":102,"namespace":"test.foo","query":{"_id":"54e792daf1845f045f4c000e","n":1099511627776,"tags":["a",null,true],"when":"` + time.Unix(1412941647, 719000000).Format(time.RFC3339Nano) + `"},"ratio":1.5}` + "\n",
+		},
+		{
+			"csv", []string{"namespace", "duration", "ratio", "missing", "query"},
+			"namespace,duration,ratio,missing,query\n" +
+				`test.foo,102,1.5,,"{""_id"":""54e792daf1845f045f4c000e"",""n"":1099511627776,""tags"":[""a"",null,true],""when"":""` + time.Unix(1412941647, 719000000).Format(time.RFC3339Nano) + `""}"` + "\n",
+		},
+		{
+			"tsv", []string{"namespace", "duration"},
+			"namespace\tduration\ntest.foo\t102\n",
+		},
+	}
+	for _, testcase := range cases {
+		var buf bytes.Buffer
+		enc, err := GetFormat(testcase.format, &buf, testcase.columns)
+		if err != nil {
+			t.Fatalf("%s: %v", testcase.format, err)
+		}
+		if err = enc.Encode(doc); err != nil {
+			t.Fatalf("%s: error encoding: %v", testcase.format, err)
+		}
+		if err = enc.Flush(); err != nil {
+			t.Fatalf("%s: error flushing: %v", testcase.format, err)
+		}
+		if buf.String() != testcase.expected {
+			t.Errorf("%s: expected '%s'\nbut got '%s'", testcase.format, testcase.expected, buf.String())
+		}
+	}
+
+	if _, err := GetFormat("csv", &bytes.Buffer{}, nil); err != ErrNoColumns {
+		t.Errorf("expected ErrNoColumns, got %v", err)
+	}
+}
+
+func TestBSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	enc, _ := GetFormat("bson", &buf, nil)
+	enc.Encode(map[string]interface{}{"a": float64(1)})
+	enc.Encode(map[string]interface{}{"b": "x"})
+
+	expected := []byte("\x0c\x00\x00\x00\x10a\x00\x01\x00\x00\x00\x00" +
+		"\x0e\x00\x00\x00\x02b\x00\x02\x00\x00\x00x\x00\x00")
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("expected %q\nbut got %q", expected, buf.Bytes())
+	}
+}
+
+func TestObjectIDFormats(t *testing.T) {
+	// token is a string that only looks like an ObjectId
+	fields, err := parser.ParseLogLine(`2015-03-04T11:31:45.116-0800 I WRITE    [conn2] remove test.foo query: { _id: ObjectId('54e792daf1845f045f4c000e'), token: "0123456789abcdef01234567" } ndeleted:1 0ms`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := fields["query"].(map[string]interface{})
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{"json", `{"_id":"54e792daf1845f045f4c000e","token":"0123456789abcdef01234567"}` + "\n"},
+		{"extjson", `{"_id":{"$oid":"54e792daf1845f045f4c000e"},"token":"0123456789abcdef01234567"}` + "\n"},
+		{"bson", "\x3a\x00\x00\x00\x07_id\x00\x54\xe7\x92\xda\xf1\x84\x5f\x04\x5f\x4c\x00\x0e" +
+			"\x02token\x00\x19\x00\x00\x000123456789abcdef01234567\x00\x00"},
+	}
+	for _, testcase := range cases {
+		var buf bytes.Buffer
+		enc, _ := GetFormat(testcase.format, &buf, nil)
+		if err = enc.Encode(query); err != nil {
+			t.Fatalf("%s: error encoding: %v", testcase.format, err)
+		}
+		if buf.String() != testcase.expected {
+			t.Errorf("%s: expected %q\nbut got %q", testcase.format, testcase.expected, buf.String())
+		}
+	}
+}
@@ -1,30 +1,244 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/toshok/mongologtools/ioreg"
 	"github.com/toshok/mongologtools/parser"
 )
 
-func ingest(r io.Reader, w io.Writer) error {
-	s := bufio.NewScanner(r)
-	out := json.NewEncoder(w)
-	for s.Scan() {
-		r, err := parser.ParseLogLine(s.Text())
-		if err != nil {
-			log.Printf("line parsing err on `%s..`\n", string(s.Bytes()[:min(len(s.Text()), 30)]))
+// ingestStats counts what happened to the input lines
+type ingestStats struct {
+	parsed int
+	// filtered is how many of the parsed lines didn't match the filter
+	filtered int
+	failed   int
+	skipped  int
+}
+
+func (s ingestStats) String() string {
+	return fmt.Sprintf("parsed %d (filtered out %d), failed %d, skipped %d line(s)", s.parsed, s.filtered, s.failed, s.skipped)
+}
+
+// failureRate returns the fraction of non-blank lines that failed to parse
+func (s ingestStats) failureRate() float64 {
+	if s.parsed+s.failed == 0 {
+		return 0
+	}
+	return float64(s.failed) / float64(s.parsed+s.failed)
+}
+
+// deadLetter is what's written to the dead-letter output for a line that failed to parse
+type deadLetter struct {
+	// Source is the input the line came from when merging several
+	Source   string `json:"source,omitempty"`
+	Line     int    `json:"line"`
+	Offset   int    `json:"offset"`
+	Expected string `json:"expected,omitempty"`
+	Error    string `json:"error"`
+	Text     string `json:"text"`
+}
+
+// logTimestamps decodes the ctime timestamps of the inputs in the year and zone of
+// -year and -tz.  Each log is decoded with a copy of its own.
+var logTimestamps parser.TimestampParser
+
+// ingester writes parsed lines that keep returns true for to the output, and lines
+// that failed to parse to the dead-letter output, or logs them if there is none.
+// Blank lines are skipped.
+type ingester struct {
+	out     Encoder
+	deadOut *json.Encoder
+	keep    func(*parser.LogEntry) bool
+	stats   ingestStats
+
+	// checkpoints are the inputs that can save how far they've been handled, or nil
+	// for those that can't, and handled is the offset just past the last line
+	// handled from each.  They're committed each time out is flushed.
+	checkpoints []ioreg.Checkpointer
+	handled     []int64
+
+	// mu serializes the writes to out between the goroutine reading the input and
+	// the one flushing it every interval, and flushErr is the error that stopped
+	// the latter
+	mu       sync.Mutex
+	flushErr error
+}
+
+// newIngester returns an ingester.  keep may be nil, to write every line.  It's
+// called from several goroutines by ingestParallel.
+func newIngester(out Encoder, dead io.Writer, keep func(*parser.LogEntry) bool) *ingester {
+	in := &ingester{out: out, keep: keep}
+	if dead != nil {
+		in.deadOut = json.NewEncoder(dead)
+	}
+	return in
+}
+
+// checkpointInputs has the ingester commit how far it got in those of rs, the inputs
+// in order, that are ioreg.Checkpointers once the lines are flushed to the output
+func (in *ingester) checkpointInputs(rs []io.ReadCloser) {
+	in.checkpoints = make([]ioreg.Checkpointer, len(rs))
+	in.handled = make([]int64, len(rs))
+	for i, r := range rs {
+		in.checkpoints[i], _ = r.(ioreg.Checkpointer)
+	}
+}
+
+// handledThrough records that the lines of input up to offset have been handled
+func (in *ingester) handledThrough(input int, offset int64) {
+	if in.handled == nil {
+		return
+	}
+	in.mu.Lock()
+	in.handled[input] = offset
+	in.mu.Unlock()
+}
+
+// handle takes the result of parsing a line.  It only returns an error when the line
+// can't be written.
+func (in *ingester) handle(entry *parser.LogEntry, err error) error {
+	return in.handleFrom("", entry, err)
+}
+
+// handleFrom is handle for a line from one of several inputs, which is tagged with
+// source unless it's empty
+func (in *ingester) handleFrom(source string, entry *parser.LogEntry, err error) error {
+	if err == nil && source != "" {
+		entry.Fields["source"] = source
+	}
+	return in.handleKept(source, entry, err, err == nil && in.kept(entry))
+}
+
+// kept returns whether entry is to be written
+func (in *ingester) kept(entry *parser.LogEntry) bool {
+	return in.keep == nil || in.keep(entry)
+}
+
+// handleKept is handleFrom for a line that's already been filtered, with kept
+// whether it's to be written
+func (in *ingester) handleKept(source string, entry *parser.LogEntry, err error, kept bool) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.flushErr != nil {
+		return in.flushErr
+	}
+
+	if perr, ok := err.(*parser.ParseError); ok {
+		return in.handleParseError(source, perr)
+	}
+	if err != nil {
+		return err
+	}
+	in.stats.parsed++
+	if !kept {
+		in.stats.filtered++
+		return nil
+	}
+	return in.out.Encode(entry.Fields)
+}
+
+func (in *ingester) handleParseError(source string, perr *parser.ParseError) error {
+	if strings.TrimSpace(perr.Text) == "" {
+		in.stats.skipped++
+		return nil
+	}
+	in.stats.failed++
+	if in.deadOut == nil {
+		if source != "" {
+			log.Printf("line parsing err: %s: %v\n", source, perr)
+		} else {
+			log.Printf("line parsing err: %v\n", perr)
+		}
+		return nil
+	}
+	return in.deadOut.Encode(deadLetter{
+		Source:   source,
+		Line:     perr.Line,
+		Offset:   perr.Offset,
+		Expected: perr.Expected,
+		Error:    perr.Err.Error(),
+		Text:     perr.Text,
+	})
+}
+
+// flush flushes the output
+func (in *ingester) flush() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.flushErr != nil {
+		return in.flushErr
+	}
+	return in.flushLocked()
+}
+
+// flushLocked flushes the output and then commits the lines it held to the inputs'
+// checkpoints.  The caller holds mu.
+func (in *ingester) flushLocked() error {
+	if err := in.out.Flush(); err != nil {
+		return err
+	}
+	for i, c := range in.checkpoints {
+		if c == nil {
+			continue
+		}
+		if err := c.Commit(in.handled[i]); err != nil {
+			return err
 		}
-		out.Encode(r)
 	}
 	return nil
 }
 
-func min(n, m int) int {
-	if n < m {
-		return n
+// flushEvery flushes the output every interval until the returned function is
+// called, so that outputs that batch documents, such as es:// and mongodb://, send
+// what they have while the input is idle, as it is when following a log with
+// tail://.  An error stops the flushing, and is returned for the next line handled.
+func (in *ingester) flushEvery(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(interval)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				in.mu.Lock()
+				if in.flushErr == nil {
+					in.flushErr = in.flushLocked()
+				}
+				in.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
+// ingest parses the log lines in r and hands them to in
+func ingest(r io.Reader, in *ingester) (ingestStats, error) {
+	s := parser.NewScanner(r)
+	s.Timestamps = logTimestamps
+	for {
+		entry, err := s.Next()
+		if err == io.EOF {
+			return in.stats, in.flush()
+		}
+		if err = in.handle(entry, err); err != nil {
+			return in.stats, err
+		}
+		in.handledThrough(0, s.Offset())
 	}
-	return m
 }
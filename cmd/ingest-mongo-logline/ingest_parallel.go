@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// linesPerWorker bounds how many lines can be in flight per worker, which bounds
+// the memory used to hold lines that finished parsing before their predecessors
+const linesPerWorker = 256
+
+// parseJob is a line on its way through the pipeline
+type parseJob struct {
+	line int
+	// offset is just past the line in the input
+	offset int64
+	text   string
+	entry  *parser.LogEntry
+	err    error
+	// filtered is true once the entry has been filtered, and kept is the result
+	filtered, kept bool
+}
+
+// ingestParallel is ingest with the parsing and filtering spread over a number of
+// worker goroutines.  A reader goroutine feeds the workers, and the results are put
+// back in their original order before they are written.
+func ingestParallel(r io.Reader, in *ingester, workers int) (ingestStats, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	inFlight := make(chan struct{}, workers*linesPerWorker)
+	jobs := make(chan *parseJob, workers*linesPerWorker)
+	results := make(chan *parseJob, workers*linesPerWorker)
+
+	var readErr error
+	go func() {
+		defer close(jobs)
+		readErr = readLines(r, jobs, inFlight, done)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.parse(in)
+				select {
+				case results <- job:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// the year of ctime timestamps depends on the lines before, so those are
+	// decoded here rather than in the workers
+	tp := logTimestamps
+	pending := make(map[int]*parseJob)
+	next := 1
+	for job := range results {
+		pending[job.line] = job
+		for job, ok := pending[next]; ok; job, ok = pending[next] {
+			delete(pending, next)
+			next++
+			<-inFlight
+
+			job.date(&tp, in)
+			if err := in.handleKept("", job.entry, job.err, job.kept); err != nil {
+				return in.stats, err
+			}
+			in.handledThrough(0, job.offset)
+		}
+	}
+	if readErr != nil {
+		return in.stats, readErr
+	}
+	return in.stats, in.flush()
+}
+
+// parse parses the line of a job and filters the entry, unless its timestamp has
+// to wait for date
+func (job *parseJob) parse(in *ingester) {
+	job.entry, job.err = parser.ParseUndated(job.text)
+	if job.err != nil {
+		if perr, ok := job.err.(*parser.ParseError); ok {
+			perr.Line = job.line
+		}
+		return
+	}
+	if !job.entry.Timestamp.IsZero() {
+		job.kept, job.filtered = in.kept(job.entry), true
+	}
+}
+
+// date decodes the timestamp parse left, which tp has to do in the order of the
+// lines, and filters the entry with it.  What's left is what parser.Scanner would
+// have returned.
+func (job *parseJob) date(tp *parser.TimestampParser, in *ingester) {
+	if job.err != nil || job.filtered {
+		return
+	}
+	if err := tp.Date(job.entry); err != nil {
+		job.entry, job.err = nil, &parser.ParseError{Line: job.line, Expected: "timestamp", Text: job.text, Err: err}
+		return
+	}
+	job.kept, job.filtered = in.kept(job.entry), true
+}
+
+func readLines(r io.Reader, jobs chan<- *parseJob, inFlight chan<- struct{}, done <-chan struct{}) error {
+	var offset int64
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		offset += int64(advance)
+		return advance, token, err
+	})
+	for line := 1; s.Scan(); line++ {
+		select {
+		case inFlight <- struct{}{}:
+		case <-done:
+			return nil
+		}
+		jobs <- &parseJob{line: line, offset: offset, text: s.Text()}
+	}
+	return s.Err()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestIngestDeadLetter(t *testing.T) {
+	input := strings.Join([]string{
+		`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1 } 102ms`,
+		``,
+		`garbage line`,
+		`2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}, "\n")
+
+	var out, dead bytes.Buffer
+	stats, err := ingest(strings.NewReader(input), newIngester(newJSONEncoder(&out), &dead, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.parsed != 2 || stats.failed != 1 || stats.skipped != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 2 {
+		t.Errorf("expected 2 output lines, got %d", lines)
+	}
+
+	var letter deadLetter
+	if err = json.Unmarshal(dead.Bytes(), &letter); err != nil {
+		t.Fatalf("error decoding dead letter: %v", err)
+	}
+	if letter.Line != 3 || letter.Text != "garbage line" || letter.Error == "" {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+}
+
+// checkpointLog records what happens to the output and the checkpoints of the input
+type checkpointLog struct {
+	io.Reader
+	events []string
+}
+
+func (l *checkpointLog) Encode(doc map[string]interface{}) error {
+	l.events = append(l.events, "encode")
+	return nil
+}
+
+func (l *checkpointLog) Flush() error {
+	l.events = append(l.events, "flush")
+	return nil
+}
+
+func (l *checkpointLog) Commit(offset int64) error {
+	l.events = append(l.events, fmt.Sprintf("commit %d", offset))
+	return nil
+}
+
+func (l *checkpointLog) Close() error { return nil }
+
+func TestIngestCheckpoint(t *testing.T) {
+	input := "2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017\n"
+	input += input
+	expected := fmt.Sprintf("encode encode flush commit %d", len(input))
+
+	l := &checkpointLog{Reader: strings.NewReader(input)}
+	in := newIngester(l, nil, nil)
+	in.checkpointInputs([]io.ReadCloser{l})
+	if _, err := ingest(l, in); err != nil {
+		t.Fatal(err)
+	}
+	if events := strings.Join(l.events, " "); events != expected {
+		t.Errorf("expected '%s', got '%s'", expected, events)
+	}
+
+	l = &checkpointLog{Reader: strings.NewReader(input)}
+	in = newIngester(l, nil, nil)
+	in.checkpointInputs([]io.ReadCloser{l})
+	if _, err := ingestParallel(l, in, 2); err != nil {
+		t.Fatal(err)
+	}
+	if events := strings.Join(l.events, " "); events != expected {
+		t.Errorf("parallel: expected '%s', got '%s'", expected, events)
+	}
+}
+
+// benchmarkInput returns a log of n lines in a mix of formats, with the odd blank
+// and unparseable line
+func benchmarkInput(n int) string {
+	lines := []string{
+		`Mon Feb 23 03:20:19.670 [TTLMonitor] query local.system.indexes query: { expireAfterSeconds: { $exists: true } } ntoreturn:0 ntoskip:0 nscanned:0 keyUpdates:0 locks(micros) r:86 nreturned:0 reslen:20 0ms`,
+		`2015-03-04T11:31:45.116-0800 I COMMAND  [conn2] command test.$cmd command: count { count: "foo", query: { a: 1, b: { $in: [ 1, 2, 3 ] } } } planSummary: COUNT_SCAN { b: 1, a: 1 } keyUpdates:0 writeConflicts:0 numYields:0 reslen:44 locks:{ Global: { acquireCount: { r: 2 } }, Database: { acquireCount: { r: 1 } } } 2ms`,
+		`2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] connection accepted from 127.0.0.1:53422 #1 (1 connection now open)`,
+		`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.foo","command":{"find":"foo","filter":{"a":{"$gt":5}},"$db":"test"},"planSummary":"IXSCAN { a: 1 }","keysExamined":10,"docsExamined":10,"nreturned":10,"reslen":1234,"durationMillis":105}}`,
+		``,
+		`garbage line`,
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(lines[i%len(lines)])
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestIngestParallel(t *testing.T) {
+	input := benchmarkInput(10000)
+	// keeps the lines that depend on the order of the keys of the index, and the ctime
+	// ones, whose timestamps are decoded after the workers are done
+	keep := func(entry *parser.LogEntry) bool {
+		if ps := entry.PlanSummary; ps != nil && len(ps.IndexesUsed()) != 0 {
+			return ps.IndexesUsed()[0].String() == "{ b: 1, a: 1 }"
+		}
+		return entry.Timestamp.Month() == time.February
+	}
+
+	for _, keep := range []func(*parser.LogEntry) bool{nil, keep} {
+		var serialOut, serialDead bytes.Buffer
+		serialStats, err := ingest(strings.NewReader(input), newIngester(newJSONEncoder(&serialOut), &serialDead, keep))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keep != nil && (serialStats.filtered == 0 || serialStats.filtered == serialStats.parsed) {
+			t.Errorf("expected some of the lines to be filtered out, got %v", serialStats)
+		}
+
+		for _, workers := range []int{1, 2, 8} {
+			var out, dead bytes.Buffer
+			stats, err := ingestParallel(strings.NewReader(input), newIngester(newJSONEncoder(&out), &dead, keep), workers)
+			if err != nil {
+				t.Fatalf("%d workers: unexpected error: %v", workers, err)
+			}
+			if stats != serialStats {
+				t.Errorf("%d workers: expected stats %v, got %v", workers, serialStats, stats)
+			}
+			if out.String() != serialOut.String() || dead.String() != serialDead.String() {
+				t.Errorf("%d workers: output differs from serial ingest", workers)
+			}
+		}
+	}
+}
+
+func BenchmarkIngestSerial(b *testing.B) {
+	input := benchmarkInput(10000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		ingest(strings.NewReader(input), newIngester(newJSONEncoder(ioutil.Discard), ioutil.Discard, nil))
+	}
+}
+
+func BenchmarkIngestParallel(b *testing.B) {
+	input := benchmarkInput(10000)
+	for _, workers := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				ingestParallel(strings.NewReader(input), newIngester(newJSONEncoder(ioutil.Discard), ioutil.Discard, nil), workers)
+			}
+		})
+	}
+}
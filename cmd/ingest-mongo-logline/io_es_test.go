@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toshok/mongologtools/ioreg"
+)
+
+// fakeES accepts _bulk requests, rejecting the whole of the first one and the
+// first document of the second one with 429
+type fakeES struct {
+	mu        sync.Mutex
+	templates map[string]map[string]interface{}
+	bulks     int
+	indexed   map[string][]map[string]interface{}
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/_index_template/") {
+		var template map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&template)
+		f.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = template
+		fmt.Fprint(w, `{"acknowledged":true}`)
+		return
+	}
+	if r.Method != "POST" || r.URL.Path != "/_bulk" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.bulks++
+	if f.bulks == 1 {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	var items []string
+	s := bufio.NewScanner(r.Body)
+	for s.Scan() {
+		var meta struct {
+			Index struct {
+				Index string `json:"_index"`
+			} `json:"index"`
+		}
+		json.Unmarshal(s.Bytes(), &meta)
+		s.Scan()
+		var doc map[string]interface{}
+		json.Unmarshal(s.Bytes(), &doc)
+
+		if f.bulks == 2 && len(items) == 0 {
+			items = append(items, `{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}}`)
+			continue
+		}
+		f.indexed[meta.Index.Index] = append(f.indexed[meta.Index.Index], doc)
+		items = append(items, `{"index":{"status":201}}`)
+	}
+	fmt.Fprintf(w, `{"errors":true,"items":[%s]}`, strings.Join(items, ","))
+}
+
+func TestESWriter(t *testing.T) {
+	es := &fakeES{templates: map[string]map[string]interface{}{}, indexed: map[string][]map[string]interface{}{}}
+	server := httptest.NewServer(es)
+	defer server.Close()
+
+	sink, err := ioreg.GetSink(strings.Replace(server.URL, "http://", "es://", 1) + "/mongod-{2006.01.02}?batch=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ew := w.(*esWriter)
+	ew.backoff = time.Millisecond
+
+	for _, ts := range []string{"2015-02-20T14:00:00.000+0000", "2015-02-20T23:59:59.999+0000", "2015-02-21T00:00:00.000+0000"} {
+		if err = ew.Encode(map[string]interface{}{"timestamp": ts, "duration": float64(102), "namespace": "test.foo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = ew.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if es.bulks != 3 {
+		t.Errorf("expected 3 _bulk requests, got %d", es.bulks)
+	}
+	if len(es.indexed["mongod-2015.02.20"]) != 2 || len(es.indexed["mongod-2015.02.21"]) != 1 {
+		t.Errorf("expected entries in an index per day, got %v", es.indexed)
+	}
+	if doc := es.indexed["mongod-2015.02.21"][0]; doc["@timestamp"] != "2015-02-21T00:00:00Z" {
+		t.Errorf("expected @timestamp 2015-02-21T00:00:00Z, got %v", doc["@timestamp"])
+	}
+
+	template, ok := es.templates["mongod"]
+	if !ok {
+		t.Fatalf("expected the mongod index template, got %v", es.templates)
+	}
+	if patterns := template["index_patterns"].([]interface{}); len(patterns) != 1 || patterns[0] != "mongod-*" {
+		t.Errorf("expected index pattern mongod-*, got %v", patterns)
+	}
+}
+
+func TestESWriterGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := ioreg.GetSink(strings.Replace(server.URL, "http://", "es://", 1) + "?retries=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ew := w.(*esWriter)
+	ew.backoff = time.Millisecond
+	ew.Encode(map[string]interface{}{"namespace": "test.foo"})
+	if err = ew.Flush(); err == nil || !strings.Contains(err.Error(), "after 2 retries") {
+		t.Errorf("expected to give up after 2 retries, got %v", err)
+	}
+}
+
+func TestESFlushInterval(t *testing.T) {
+	es := &fakeES{templates: map[string]map[string]interface{}{}, indexed: map[string][]map[string]interface{}{}}
+	server := httptest.NewServer(es)
+	defer server.Close()
+
+	sink, err := ioreg.GetSink(strings.Replace(server.URL, "http://", "es://", 1) + "/mongod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ew := w.(*esWriter)
+	ew.backoff = time.Millisecond
+
+	// the input stays open, as a followed log does, with far less than a batch in it
+	r, pw := io.Pipe()
+	in := newIngester(ew, nil, nil)
+	stop := in.flushEvery(10 * time.Millisecond)
+	done := make(chan error)
+	go func() {
+		_, err := ingest(r, in)
+		done <- err
+	}()
+	for _, ts := range []string{"2015-02-20T14:00:00.000+0000", "2015-02-20T14:00:01.000+0000"} {
+		fmt.Fprintf(pw, "%s I NETWORK  [initandlisten] waiting for connections on port 27017\n", ts)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		es.mu.Lock()
+		n := len(es.indexed["mongod"])
+		es.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the entries to be sent while the input is idle, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pw.Close()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
+
+func TestESHTTPSCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var auths []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		mu.Lock()
+		auths = append(auths, user+":"+password)
+		mu.Unlock()
+		if r.URL.Path == "/_bulk" {
+			fmt.Fprint(w, `{"errors":false,"items":[{"index":{"status":201}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	}))
+	defer server.Close()
+
+	sink, err := ioreg.GetSink(strings.Replace(server.URL, "https://", "es+https://elastic:p%40ss@", 1) + "/mongod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ew := w.(*esWriter)
+	if ew.url != server.URL {
+		t.Errorf("expected %s, got %s", server.URL, ew.url)
+	}
+	// trust the test server's certificate
+	ew.client = server.Client()
+	ew.Encode(map[string]interface{}{"namespace": "test.foo"})
+	if err = ew.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 2 || auths[0] != "elastic:p@ss" || auths[1] != "elastic:p@ss" {
+		t.Errorf("expected the template and _bulk requests to authenticate as elastic, got %v", auths)
+	}
+}
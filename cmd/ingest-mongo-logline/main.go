@@ -3,44 +3,219 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/toshok/mongologtools/ioreg"
+	"github.com/toshok/mongologtools/parser"
 )
 
 var (
-	flagInput  = flag.String("i", "file://-", "input io path")
-	flagOutput = flag.String("o", "file://-", "output io path")
+	flagInputs inputList
+	flagOutput = flag.String("o", "file://-", "output io path, such as es://localhost:9200/logs (es+https:// for HTTPS, and user:password@ before the host to authenticate) or mongodb://localhost/logs.mongod (add escape=true for a server before 5.0, to store the '$' and '.' it won't take in field names as '＄' and '．')")
+	flagDead   = flag.String("deadletter", "", "io path to write lines that fail to parse to (default: log them)")
+
+	flagFormat  = flag.String("format", "json", "output format: json, extjson, bson, csv or tsv")
+	flagColumns = flag.String("columns", "", "comma separated list of the fields to write in csv and tsv output")
+
+	flagFilter = flag.String("filter", "", "only write the entries matching an expression such as 'duration>100 && namespace=~\"^app\\.\"'")
+	flagFrom   = flag.String("from", "", "only write the entries at or after a time such as 2006-01-02T15:04:05 (in -tz unless a zone is given)")
+	flagTo     = flag.String("to", "", "only write the entries before a time")
+
+	flagYear = flag.Int("year", 0, "year of the first line of logs with ctime timestamps, which have none (default: the latest year its date falls on its day of the week)")
+	flagTZ   = flag.String("tz", "", "time zone of ctime timestamps, -from and -to, such as UTC or America/New_York (default local time)")
+
+	flagWorkers        = flag.Int("workers", 1, "number of goroutines parsing lines, with a single -i")
+	flagFlushInterval  = flag.Duration("flush-interval", time.Second, "how often to flush the output, so batched outputs such as es:// keep up with tail://, after which tail:// checkpoints the lines flushed (0 to only flush full batches)")
+	flagMaxFailureRate = flag.Float64("max-failure-rate", 1, "exit non-zero if more than this fraction of lines fail to parse")
 )
 
+// inputList is the -i flag, which can be repeated
+type inputList []string
+
+func (l *inputList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *inputList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func init() {
+	flag.Var(&flagInputs, "i", "input io path (default file://-); repeat it to merge several inputs by timestamp")
+}
+
 func main() {
 	flag.Parse()
+	if len(flagInputs) == 0 {
+		flagInputs = inputList{"file://-"}
+	}
 	if len(flag.Args()) != 0 {
-		fmt.Fprintf(os.Stderr, "unexpected argument(s):", flag.Args())
+		fmt.Fprintln(os.Stderr, "unexpected argument(s):", flag.Args())
 		os.Exit(1)
 	}
-	input, err := GetIO(*flagInput)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error configurting input:", err)
+	if *flagWorkers > 1 && len(flagInputs) > 1 {
+		fmt.Fprintln(os.Stderr, "-workers can't be used with more than one -i")
 		os.Exit(1)
 	}
-	r, err := input.Reader()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error opening input:", err)
+	logTimestamps.Year = *flagYear
+	if *flagTZ != "" {
+		loc, err := time.LoadLocation(*flagTZ)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error in -tz:", err)
+			os.Exit(1)
+		}
+		logTimestamps.Location = loc
+	}
+
+	// the outputs opened so far, which are discarded if we fail part way through
+	var outputs []io.WriteCloser
+	fail := func(msg string, err error) {
+		fmt.Fprintln(os.Stderr, msg, err)
+		for _, w := range outputs {
+			discard(w)
+		}
 		os.Exit(1)
 	}
 
-	output, err := GetIO(*flagOutput)
+	var rs []io.ReadCloser
+	for _, path := range flagInputs {
+		input, err := ioreg.GetSource(path)
+		if err != nil {
+			fail("error configurting input:", err)
+		}
+		r, err := input.Reader()
+		if err != nil {
+			fail("error opening input:", err)
+		}
+		rs = append(rs, r)
+	}
+
+	output, err := ioreg.GetSink(*flagOutput)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error configurting output:", err)
-		os.Exit(1)
+		fail("error configurting output:", err)
 	}
 	w, err := output.Writer()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error opening output:", err)
-		os.Exit(1)
+		fail("error opening output:", err)
 	}
+	outputs = append(outputs, w)
 
-	if err := ingest(r, w); err != nil {
-		fmt.Fprintln(os.Stderr, "error ingesting:", err)
-		os.Exit(1)
+	var columns []string
+	if *flagColumns != "" {
+		columns = strings.Split(*flagColumns, ",")
+	}
+	// outputs such as mongodb:// take the parsed documents themselves
+	out, ok := w.(Encoder)
+	if !ok {
+		if out, err = GetFormat(*flagFormat, w, columns); err != nil {
+			fail("error configuring output format:", err)
+		}
+	}
+
+	var dead io.WriteCloser
+	if *flagDead != "" {
+		deadOutput, err := ioreg.GetSink(*flagDead)
+		if err != nil {
+			fail("error configuring dead-letter output:", err)
+		}
+		if dead, err = deadOutput.Writer(); err != nil {
+			fail("error opening dead-letter output:", err)
+		}
+		outputs = append(outputs, dead)
+	}
+
+	var keeps []func(*parser.LogEntry) bool
+	if *flagFilter != "" {
+		f, err := compileFilter(*flagFilter)
+		if err != nil {
+			fail("error in -filter:", err)
+		}
+		keeps = append(keeps, f.match)
+	}
+
+	// lines are read from ins, which are the parts of the inputs within -from and -to
+	// when they can be searched
+	ins := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		ins[i] = r
+	}
+	if *flagFrom != "" || *flagTo != "" {
+		tr := timeRange{timestamps: logTimestamps}
+		loc := logTimestamps.Location
+		if loc == nil {
+			loc = time.Local
+		}
+		if *flagFrom != "" {
+			if tr.from, err = parseTimeFlag(*flagFrom, loc); err != nil {
+				fail("error in -from:", err)
+			}
+		}
+		if *flagTo != "" {
+			if tr.to, err = parseTimeFlag(*flagTo, loc); err != nil {
+				fail("error in -to:", err)
+			}
+		}
+		for i, r := range rs {
+			if ins[i], err = tr.section(r); err != nil {
+				fail("error searching input:", err)
+			}
+		}
+		keeps = append(keeps, tr.match)
+	}
+	var keep func(*parser.LogEntry) bool
+	if len(keeps) != 0 {
+		keep = func(entry *parser.LogEntry) bool {
+			for _, k := range keeps {
+				if !k(entry) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	in := newIngester(out, dead, keep)
+	in.checkpointInputs(rs)
+	stopFlushing := in.flushEvery(*flagFlushInterval)
+	var stats ingestStats
+	switch {
+	case len(ins) > 1:
+		stats, err = ingestMerged(ins, flagInputs, in)
+	case *flagWorkers > 1:
+		stats, err = ingestParallel(ins[0], in, *flagWorkers)
+	default:
+		stats, err = ingest(ins[0], in)
+	}
+	stopFlushing()
+	fmt.Fprintln(os.Stderr, stats)
+	for _, r := range rs {
+		r.Close()
+	}
+	if err != nil {
+		fail("error ingesting:", err)
+	}
+	for len(outputs) != 0 {
+		w, outputs = outputs[0], outputs[1:]
+		if err = w.Close(); err != nil {
+			fail("error closing output:", err)
+		}
+	}
+	if rate := stats.failureRate(); rate > *flagMaxFailureRate {
+		fmt.Fprintf(os.Stderr, "failure rate %.3f exceeds %.3f\n", rate, *flagMaxFailureRate)
+		os.Exit(2)
+	}
+}
+
+// discard closes an output that's incomplete.  Outputs that can, such as files
+// written atomically, are left as they were before we started.
+func discard(w io.WriteCloser) {
+	if a, ok := w.(ioreg.Aborter); ok {
+		a.Abort()
+		return
 	}
+	w.Close()
 }
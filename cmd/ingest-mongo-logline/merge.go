@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// ingestMerged is ingest for several inputs, such as the logs of the members of a
+// replica set, which are merged into one stream in timestamp order.  Each entry is
+// tagged with the name of its input in a "source" field.
+func ingestMerged(rs []io.Reader, names []string, in *ingester) (ingestStats, error) {
+	var scanners []*parser.Scanner
+	for _, r := range rs {
+		s := parser.NewScanner(r)
+		s.Timestamps = logTimestamps
+		scanners = append(scanners, s)
+	}
+	m := parser.NewMerger(scanners...)
+	for {
+		entry, i, err := m.Next()
+		if err == io.EOF {
+			return in.stats, in.flush()
+		}
+		if err = in.handleFrom(names[i], entry, err); err != nil {
+			return in.stats, err
+		}
+		// the Merger reads an input's next line only once this one is returned
+		in.handledThrough(i, scanners[i].Offset())
+	}
+}
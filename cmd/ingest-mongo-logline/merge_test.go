@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIngestMerged(t *testing.T) {
+	rs := []io.Reader{
+		strings.NewReader(strings.Join([]string{
+			`2015-03-04T11:31:45.100Z I REPL     [ReplicationExecutor] transition to PRIMARY`,
+			`2015-03-04T11:31:45.300Z I NETWORK  [conn2] end connection 127.0.0.1:53422`,
+		}, "\n")),
+		strings.NewReader(strings.Join([]string{
+			`garbage line`,
+			`2015-03-04T11:31:45.200Z I REPL     [ReplicationExecutor] transition to SECONDARY`,
+		}, "\n")),
+	}
+	names := []string{"file://rs1.log", "file://rs2.log"}
+
+	var out, dead bytes.Buffer
+	stats, err := ingestMerged(rs, names, newIngester(newJSONEncoder(&out), &dead, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.parsed != 3 || stats.failed != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+
+	var sources []string
+	dec := json.NewDecoder(&out)
+	for {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, doc["source"].(string))
+	}
+	expected := "file://rs1.log file://rs2.log file://rs1.log"
+	if strings.Join(sources, " ") != expected {
+		t.Errorf("expected sources '%s'\nbut got '%s'", expected, strings.Join(sources, " "))
+	}
+
+	var letter deadLetter
+	if err = json.Unmarshal(dead.Bytes(), &letter); err != nil {
+		t.Fatal(err)
+	}
+	if letter.Source != "file://rs2.log" || letter.Line != 1 {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+}
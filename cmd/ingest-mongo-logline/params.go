@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// intParam returns the value of a non-negative integer query parameter, or def if it's
+// not given
+func intParam(params url.Values, name string, def int) (int, error) {
+	s := params.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("io: %s must be a non-negative integer, not '%s'", name, s)
+	}
+	return n, nil
+}
+
+// boolParam returns the value of a true or false query parameter, which is false if
+// it's not given
+func boolParam(params url.Values, name string) (bool, error) {
+	s := params.Get(name)
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("io: %s must be true or false, not '%s'", name, s)
+	}
+	return b, nil
+}
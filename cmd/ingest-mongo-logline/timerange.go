@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/toshok/mongologtools/ioreg"
+	"github.com/toshok/mongologtools/parser"
+)
+
+// timeLayouts are the forms -from and -to accept.  Those without a zone are in the
+// zone of -tz, local time by default.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseTimeFlag(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time '%s', expected a time such as 2006-01-02T15:04:05", s)
+}
+
+// timeRange is the window of time -from and -to keep lines within.  A zero from or
+// to leaves that end open.  timestamps decodes the ctime timestamps of the inputs
+// searched.
+type timeRange struct {
+	from, to   time.Time
+	timestamps parser.TimestampParser
+}
+
+func (tr timeRange) match(entry *parser.LogEntry) bool {
+	if !tr.from.IsZero() && entry.Timestamp.Before(tr.from) {
+		return false
+	}
+	return tr.to.IsZero() || entry.Timestamp.Before(tr.to)
+}
+
+// section returns the part of r that's within the range, found by binary search if
+// r is RandomAccess.  Otherwise it returns r, leaving match to pick out the lines.
+func (tr timeRange) section(r io.Reader) (io.Reader, error) {
+	ra, ok := r.(ioreg.RandomAccess)
+	if !ok {
+		return r, nil
+	}
+	start, end := int64(0), ra.Size()
+	var err error
+	if !tr.from.IsZero() {
+		if start, err = parser.SeekTime(ra, ra.Size(), tr.from, &tr.timestamps); err != nil {
+			return nil, err
+		}
+	}
+	if !tr.to.IsZero() {
+		if end, err = parser.SeekTime(ra, ra.Size(), tr.to, &tr.timestamps); err != nil {
+			return nil, err
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return io.NewSectionReader(ra, start, end-start), nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toshok/mongologtools/ioreg"
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestTimeRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timerange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mongod.log")
+
+	var b strings.Builder
+	for i := 0; i < 60*24; i++ {
+		fmt.Fprintf(&b, "2015-03-04T%02d:%02d:00.000Z I NETWORK  [conn%d] end connection 127.0.0.1:%d\n", i/60, i%60, i, 50000+i)
+	}
+	if err = ioutil.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := parseTimeFlag("2015-03-04T14:02:00Z", time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := parseTimeFlag("2015-03-04T14:10:00Z", time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := timeRange{from: from, to: to}
+
+	source, err := ioreg.GetSource("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := source.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	in, err := tr.section(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stats, err := ingest(in, newIngester(newJSONEncoder(&out), nil, tr.match))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// only the lines in the window are read at all
+	if stats.parsed != 8 || stats.filtered != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if !strings.Contains(out.String(), `"conn842"`) || !strings.Contains(out.String(), `"conn849"`) || strings.Contains(out.String(), `"conn850"`) {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	// without searching, match picks out the same lines
+	out.Reset()
+	stats, err = ingest(strings.NewReader(b.String()), newIngester(newJSONEncoder(&out), nil, tr.match))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.parsed != 60*24 || stats.filtered != 60*24-8 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestTimeRangeCtime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timerange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mongod.log")
+
+	// Mar 4 is a Wednesday in 2015, and in later years too, so the year has to be given
+	var b strings.Builder
+	for i := 0; i < 60*24; i++ {
+		fmt.Fprintf(&b, "Wed Mar  4 %02d:%02d:00.000 [conn%d] end connection 127.0.0.1:%d\n", i/60, i%60, i, 50000+i)
+	}
+	if err = ioutil.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved parser.TimestampParser) { logTimestamps = saved }(logTimestamps)
+	logTimestamps = parser.TimestampParser{Year: 2015, Location: time.UTC}
+
+	tr := timeRange{
+		from:       time.Date(2015, time.March, 4, 14, 2, 0, 0, time.UTC),
+		to:         time.Date(2015, time.March, 4, 14, 10, 0, 0, time.UTC),
+		timestamps: logTimestamps,
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := tr.section(io.NewSectionReader(f, 0, fi.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stats, err := ingest(in, newIngester(newJSONEncoder(&out), nil, tr.match))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.parsed != 8 || stats.filtered != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2015-03-04T14:02:00Z", time.Date(2015, time.March, 4, 14, 2, 0, 0, time.UTC)},
+		{"2015-03-04T14:02:00.5-05:00", time.Date(2015, time.March, 4, 19, 2, 0, 500000000, time.UTC)},
+		{"2015-03-04T14:02:30", time.Date(2015, time.March, 4, 14, 2, 30, 0, time.Local)},
+		{"2015-03-04 14:02", time.Date(2015, time.March, 4, 14, 2, 0, 0, time.Local)},
+		{"2015-03-04", time.Date(2015, time.March, 4, 0, 0, 0, 0, time.Local)},
+	}
+	for i, c := range cases {
+		result, err := parseTimeFlag(c.input, time.Local)
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+			continue
+		}
+		if !result.Equal(c.expected) {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, c.expected, result)
+		}
+	}
+	est := time.FixedZone("EST", -5*60*60)
+	if result, err := parseTimeFlag("2015-03-04 14:02", est); err != nil || !result.Equal(time.Date(2015, time.March, 4, 19, 2, 0, 0, time.UTC)) {
+		t.Errorf("expected the time in EST, got '%s' (%v)", result, err)
+	}
+	if _, err := parseTimeFlag("14:02", time.Local); err == nil {
+		t.Errorf("expected an error for a time without a date")
+	}
+}
@@ -0,0 +1,120 @@
+// Package loginput reads the logs named on the command lines of the report
+// commands, such as mongo-log-querystats, and handles the rest of the command line
+// they have in common.
+package loginput
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toshok/mongologtools/ioreg"
+	"github.com/toshok/mongologtools/parser"
+)
+
+// ParseFlags parses the command line of a report command, whose arguments, described
+// by synopsis such as "[logfile ...]", name the logs to read.  It returns them, or
+// stdin if there are none.  about is printed after the usage line.
+func ParseFlags(synopsis string, about ...string) []string {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] %s\n", os.Args[0], synopsis)
+		for _, line := range about {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		fmt.Fprintln(os.Stderr, "A logfile can be compressed, - for stdin, or an io path such as glob:///var/log/mongodb/mongod.log*.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		return []string{"-"}
+	}
+	return flag.Args()
+}
+
+// Fatal prints msg and v to stderr and exits
+func Fatal(msg string, v interface{}) {
+	fmt.Fprintln(os.Stderr, msg, v)
+	os.Exit(1)
+}
+
+// ReportFailed prints the number of lines that couldn't be parsed to stderr, if any
+func ReportFailed(failed int) {
+	if failed != 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d unparseable line(s)\n", failed)
+	}
+}
+
+// MustCollect is Collect for a command: an error reading the logs is fatal, and the
+// lines that couldn't be parsed are reported
+func MustCollect(paths []string, add func(*parser.LogEntry)) {
+	failed, err := Collect(paths, add)
+	if err != nil {
+		Fatal("error reading input:", err)
+	}
+	ReportFailed(failed)
+}
+
+// Open opens the input at path, which is either an io path such as
+// glob:///var/log/mongodb/mongod.log* or a file name, "-" for stdin.  Compressed
+// input is decompressed.
+func Open(path string) (io.ReadCloser, error) {
+	if !strings.Contains(path, "://") {
+		if path == "-" {
+			path = "file://-"
+		} else {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, err
+			}
+			path = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		}
+	}
+	source, err := ioreg.GetSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return source.Reader()
+}
+
+// Collect calls add with each entry of the inputs at paths, opened with Open, and
+// returns how many lines couldn't be parsed.  Blank lines are skipped.
+func Collect(paths []string, add func(*parser.LogEntry)) (failed int, err error) {
+	for _, path := range paths {
+		r, err := Open(path)
+		if err != nil {
+			return failed, err
+		}
+		n, err := CollectReader(r, add)
+		failed += n
+		r.Close()
+		if err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// CollectReader is Collect for the log in r
+func CollectReader(r io.Reader, add func(*parser.LogEntry)) (failed int, err error) {
+	s := parser.NewScanner(r)
+	for {
+		entry, err := s.Next()
+		if err == io.EOF {
+			return failed, nil
+		}
+		if perr, ok := err.(*parser.ParseError); ok {
+			if strings.TrimSpace(perr.Text) != "" {
+				failed++
+			}
+			continue
+		}
+		if err != nil {
+			return failed, err
+		}
+		add(entry)
+	}
+}
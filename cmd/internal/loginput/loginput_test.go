@@ -0,0 +1,56 @@
+package loginput
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestCollect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loginput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	line := "2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017\n"
+	if err = ioutil.WriteFile(filepath.Join(dir, "mongod.log"), []byte(line+"\ngarbage line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "mongod.log.1.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(line + line))
+	zw.Close()
+	f.Close()
+
+	cases := []struct {
+		paths           []string
+		entries, failed int
+	}{
+		{[]string{filepath.Join(dir, "mongod.log")}, 1, 1},
+		{[]string{filepath.Join(dir, "mongod.log.1.gz")}, 2, 0},
+		{[]string{"glob://" + filepath.Join(dir, "mongod.log*")}, 3, 1},
+		{[]string{filepath.Join(dir, "mongod.log"), filepath.Join(dir, "mongod.log.1.gz")}, 3, 1},
+	}
+	for i, c := range cases {
+		entries := 0
+		failed, err := Collect(c.paths, func(*parser.LogEntry) { entries++ })
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if entries != c.entries || failed != c.failed {
+			t.Errorf("case %d: expected %d entries and %d failed, got %d and %d", i, c.entries, c.failed, entries, failed)
+		}
+	}
+
+	if _, err = Collect([]string{filepath.Join(dir, "missing.log")}, func(*parser.LogEntry) {}); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+var (
+	flagFormat = flag.String("format", "table", "output format: table or json")
+	flagLimit  = flag.Int("n", 0, "only report the n clients with the most churn (0 for all)")
+)
+
+func main() {
+	paths := loginput.ParseFlags("[logfile ...]")
+
+	var write func(io.Writer, *report) error
+	switch *flagFormat {
+	case "table":
+		write = writeTable
+	case "json":
+		write = writeJSON
+	default:
+		loginput.Fatal("unknown format:", *flagFormat)
+	}
+
+	stats := newStatsCollector()
+	loginput.MustCollect(paths, stats.add)
+
+	clients := stats.sorted()
+	if *flagLimit > 0 && len(clients) > *flagLimit {
+		clients = clients[:*flagLimit]
+	}
+	if err := write(os.Stdout, newReport(stats, clients)); err != nil {
+		loginput.Fatal("error writing report:", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// report is what's written for the whole log
+type report struct {
+	PeakOpen int64          `json:"peak_open"`
+	PeakAt   *time.Time     `json:"peak_at,omitempty"`
+	Clients  []*clientStats `json:"clients"`
+}
+
+func newReport(c *statsCollector, clients []*clientStats) *report {
+	r := &report{PeakOpen: c.peak, Clients: clients}
+	if !c.peakAt.IsZero() {
+		r.PeakAt = &c.peakAt
+	}
+	return r
+}
+
+func writeTable(w io.Writer, r *report) error {
+	if r.PeakAt != nil {
+		fmt.Fprintf(w, "peak open connections: %d at %s\n\n", r.PeakOpen, r.PeakAt.Format(time.RFC3339Nano))
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "client\topened\tclosed\tmean lifetime(ms)")
+	for _, g := range r.Clients {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\n", g.ClientIP, g.Opened, g.Closed, g.MeanLifetimeMS)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, r *report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// clientStats are the connections made by one client
+type clientStats struct {
+	ClientIP string `json:"client_ip"`
+	Opened   int    `json:"opened"`
+	Closed   int    `json:"closed"`
+	// MeanLifetimeMS is the mean time the connections both opened and closed
+	// within the log were open for
+	MeanLifetimeMS float64 `json:"mean_lifetime_ms"`
+
+	lifetimes []time.Duration
+}
+
+// openConnection is a connection that has been accepted but hasn't ended yet
+type openConnection struct {
+	client   string
+	accepted time.Time
+}
+
+type statsCollector struct {
+	clients map[string]*clientStats
+	open    map[int64]openConnection
+
+	// peak is the most connections that were open at once, at peakAt
+	peak   int64
+	peakAt time.Time
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		clients: make(map[string]*clientStats),
+		open:    make(map[int64]openConnection),
+	}
+}
+
+func (c *statsCollector) add(e *parser.LogEntry) {
+	conn := e.Connection
+	if conn == nil {
+		return
+	}
+
+	g, ok := c.clients[conn.ClientIP]
+	if !ok {
+		g = &clientStats{ClientIP: conn.ClientIP}
+		c.clients[conn.ClientIP] = g
+	}
+	if conn.Accepted {
+		g.Opened++
+		c.open[conn.ConnectionID] = openConnection{client: conn.ClientIP, accepted: e.Timestamp}
+	} else {
+		g.Closed++
+		if o, ok := c.open[conn.ConnectionID]; ok && o.client == conn.ClientIP {
+			g.lifetimes = append(g.lifetimes, e.Timestamp.Sub(o.accepted))
+			delete(c.open, conn.ConnectionID)
+		}
+	}
+	if conn.Open > c.peak {
+		c.peak, c.peakAt = conn.Open, e.Timestamp
+	}
+}
+
+// sorted computes the statistics of every client and returns them by descending
+// churn, the number of connections opened and closed
+func (c *statsCollector) sorted() []*clientStats {
+	rv := make([]*clientStats, 0, len(c.clients))
+	for _, g := range c.clients {
+		g.compute()
+		rv = append(rv, g)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		ci, cj := rv[i].Opened+rv[i].Closed, rv[j].Opened+rv[j].Closed
+		if ci != cj {
+			return ci > cj
+		}
+		return rv[i].ClientIP < rv[j].ClientIP
+	})
+	return rv
+}
+
+func (g *clientStats) compute() {
+	if len(g.lifetimes) == 0 {
+		return
+	}
+	var total time.Duration
+	for _, d := range g.lifetimes {
+		total += d
+	}
+	g.MeanLifetimeMS = float64(total) / float64(time.Millisecond) / float64(len(g.lifetimes))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+func TestStatsCollector(t *testing.T) {
+	lines := []string{
+		`2015-03-04T11:31:45.000Z I NETWORK  [initandlisten] connection accepted from 10.0.0.5:53422 #1 (1 connection now open)`,
+		`2015-03-04T11:31:46.000Z I NETWORK  [initandlisten] connection accepted from 10.0.0.6:53423 #2 (2 connections now open)`,
+		`2015-03-04T11:31:46.500Z I NETWORK  [conn1] end connection 10.0.0.5:53422 (1 connection now open)`,
+		`2015-03-04T11:31:47.000Z I NETWORK  [initandlisten] connection accepted from 10.0.0.5:53424 #3 (2 connections now open)`,
+		`2015-03-04T11:31:47.100Z I NETWORK  [initandlisten] connection accepted from 10.0.0.5:53425 #4 (3 connections now open)`,
+		`2015-03-04T11:31:48.000Z I NETWORK  [conn3] end connection 10.0.0.5:53424 (2 connections now open)`,
+		`2015-03-04T11:31:48.116Z I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}
+	c := newStatsCollector()
+	if failed, err := loginput.CollectReader(strings.NewReader(strings.Join(lines, "\n")), c.add); failed != 0 || err != nil {
+		t.Fatalf("expected every line to parse, got %d failed and %v", failed, err)
+	}
+
+	clients := c.sorted()
+	if len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %d", len(clients))
+	}
+	g := clients[0]
+	if g.ClientIP != "10.0.0.5" || g.Opened != 3 || g.Closed != 2 || g.MeanLifetimeMS != 1250 {
+		t.Errorf("unexpected stats for first client: %+v", g)
+	}
+	if g = clients[1]; g.ClientIP != "10.0.0.6" || g.Opened != 1 || g.Closed != 0 || g.MeanLifetimeMS != 0 {
+		t.Errorf("unexpected stats for second client: %+v", g)
+	}
+	if c.peak != 3 || c.peakAt.Format("15:04:05.000") != "11:31:47.100" {
+		t.Errorf("expected a peak of 3 at 11:31:47.100, got %d at %s", c.peak, c.peakAt)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// indexUsage is how many operations used an index
+type indexUsage struct {
+	Index string `json:"index"`
+	Count int    `json:"count"`
+}
+
+// scanShape is a query shape that ran as a collection scan
+type scanShape struct {
+	Pattern  string `json:"pattern"`
+	Count    int    `json:"count"`
+	Examined int64  `json:"examined"`
+	Returned int64  `json:"returned"`
+	// Ratio is Examined/Returned, or Examined when nothing was returned
+	Ratio float64 `json:"ratio"`
+	// Suggestion is a candidate index, if one can be built from the shape
+	Suggestion string `json:"suggestion,omitempty"`
+
+	queryShape, sortShape string
+}
+
+// namespaceReport is what's reported for one namespace
+type namespaceReport struct {
+	Namespace       string        `json:"namespace"`
+	Indexes         []*indexUsage `json:"indexes"`
+	CollectionScans []*scanShape  `json:"collection_scans"`
+
+	indexes map[string]*indexUsage
+	scans   map[string]*scanShape
+}
+
+type advisor struct {
+	namespaces map[string]*namespaceReport
+}
+
+func newAdvisor() *advisor {
+	return &advisor{namespaces: make(map[string]*namespaceReport)}
+}
+
+func (a *advisor) add(e *parser.LogEntry) {
+	if e.Operation == "" || e.PlanSummary == nil {
+		return
+	}
+
+	ns := e.Namespace.String()
+	r, ok := a.namespaces[ns]
+	if !ok {
+		r = &namespaceReport{
+			Namespace: ns,
+			indexes:   make(map[string]*indexUsage),
+			scans:     make(map[string]*scanShape),
+		}
+		a.namespaces[ns] = r
+	}
+
+	for _, k := range e.PlanSummary.IndexesUsed() {
+		index := k.String()
+		u, ok := r.indexes[index]
+		if !ok {
+			u = &indexUsage{Index: index}
+			r.indexes[index] = u
+		}
+		u.Count++
+	}
+
+	if !e.PlanSummary.IsCollectionScan() || (e.QueryShape == "" && e.SortShape == "") {
+		return
+	}
+	p := e.QueryPattern()
+	s, ok := r.scans[p]
+	if !ok {
+		s = &scanShape{Pattern: p, queryShape: e.QueryShape, sortShape: e.SortShape}
+		r.scans[p] = s
+	}
+	s.Count++
+	// < 3.2 counts the documents of a collection scan in nscanned or nscannedObjects
+	// depending on the version, and later versions in docsExamined
+	examined := e.NScannedObjects
+	if e.NScanned > examined {
+		examined = e.NScanned
+	}
+	s.Examined += examined
+	s.Returned += e.NReturned
+}
+
+// report returns the namespaces in name order, leaving out collection scans with
+// a ratio below minRatio.  Indexes are listed most used first, and collection
+// scans by descending documents examined.
+func (a *advisor) report(minRatio float64) []*namespaceReport {
+	rv := make([]*namespaceReport, 0, len(a.namespaces))
+	for _, r := range a.namespaces {
+		r.Indexes = r.Indexes[:0]
+		for _, u := range r.indexes {
+			r.Indexes = append(r.Indexes, u)
+		}
+		sort.Slice(r.Indexes, func(i, j int) bool {
+			if r.Indexes[i].Count != r.Indexes[j].Count {
+				return r.Indexes[i].Count > r.Indexes[j].Count
+			}
+			return r.Indexes[i].Index < r.Indexes[j].Index
+		})
+
+		r.CollectionScans = r.CollectionScans[:0]
+		for _, s := range r.scans {
+			s.Ratio = float64(s.Examined)
+			if s.Returned != 0 {
+				s.Ratio /= float64(s.Returned)
+			}
+			if s.Ratio < minRatio {
+				continue
+			}
+			if k := suggestIndex(s.queryShape, s.sortShape); len(k) != 0 {
+				s.Suggestion = k.String()
+			}
+			r.CollectionScans = append(r.CollectionScans, s)
+		}
+		sort.Slice(r.CollectionScans, func(i, j int) bool {
+			if r.CollectionScans[i].Examined != r.CollectionScans[j].Examined {
+				return r.CollectionScans[i].Examined > r.CollectionScans[j].Examined
+			}
+			return r.CollectionScans[i].Pattern < r.CollectionScans[j].Pattern
+		})
+		rv = append(rv, r)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Namespace < rv[j].Namespace })
+	return rv
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestAdvisor(t *testing.T) {
+	lines := []string{
+		`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { query: { status: "A", age: { $gt: 30 } }, orderby: { created: -1 } } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:1000 nreturned:2 reslen:40 locks:{} 100ms`,
+		`2015-03-04T11:31:46.116-0800 I QUERY    [conn2] query test.foo query: { query: { status: "B", age: { $gt: 40 } }, orderby: { created: -1 } } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:1000 nreturned:0 reslen:40 locks:{} 100ms`,
+		// returns most of what it reads, so it isn't reported
+		`2015-03-04T11:31:47.116-0800 I QUERY    [conn2] query test.foo query: { b: 2 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:10 nreturned:5 reslen:40 locks:{} 20ms`,
+		`2015-03-04T11:31:48.116-0800 I QUERY    [conn2] query test.foo query: { a: 1, b: 2 } planSummary: IXSCAN { b: 1, a: 1 } ntoreturn:0 ntoskip:0 nscanned:1 nscannedObjects:1 nreturned:1 reslen:40 locks:{} 1ms`,
+		`2015-03-04T11:31:49.116-0800 I QUERY    [conn2] query test.foo query: { $or: [ { a: 1 }, { b: 2 } ] } planSummary: IXSCAN { b: 1, a: 1 }, IXSCAN { a: 1 } ntoreturn:0 ntoskip:0 nscanned:2 nscannedObjects:2 nreturned:1 reslen:40 locks:{} 1ms`,
+		`2015-03-04T11:31:50.116-0800 I QUERY    [conn3] query test.bar query: { $or: [ { a: 1 }, { b: 2 } ] } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:500 nreturned:1 reslen:40 locks:{} 50ms`,
+		`2015-03-04T11:31:51.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}
+	a := newAdvisor()
+	if failed, err := loginput.CollectReader(strings.NewReader(strings.Join(lines, "\n")), a.add); failed != 0 || err != nil {
+		t.Fatalf("expected every line to parse, got %d failed and %v", failed, err)
+	}
+
+	var out bytes.Buffer
+	if err := writeTable(&out, a.report(10)); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		`test.bar`,
+		`  collection scan            count  examined  returned  ratio  suggested index`,
+		`  {"$or":[{"a":1},{"b":1}]}  1      500       1         500.0  -`,
+		``,
+		`test.foo`,
+		`  index                                              count`,
+		`  { b: 1, a: 1 }                                     2`,
+		`  { a: 1 }                                           1`,
+		`  collection scan                                    count  examined  returned  ratio   suggested index`,
+		`  {"age":{"$gt":1},"status":1} sort: {"created":-1}  2      2000      2         1000.0  { status: 1, created: -1, age: 1 }`,
+	}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, out.String())
+	}
+}
+
+func TestSuggestIndex(t *testing.T) {
+	cases := []struct{ query, sort, expected string }{
+		{`{"a":1}`, ``, `{ a: 1 }`},
+		{`{"b":1,"a":{"$in":1}}`, ``, `{ a: 1, b: 1 }`},
+		{`{"a":{"$gte":1,"$lt":1},"b":{"$elemMatch":{"c":1}}}`, `{"d":1}`, `{ b: 1, d: 1, a: 1 }`},
+		{`{"$and":[{"a":1},{"b":{"$ne":1}}]}`, ``, `{ a: 1, b: 1 }`},
+		{`{"a":1}`, `{"a":-1,"score":{"$meta":"textScore"}}`, `{ a: 1 }`},
+		{`{"$or":[{"a":1},{"b":1}]}`, ``, `{  }`},
+		{``, `{"c":-1}`, `{ c: -1 }`},
+		// the sort fields stay in the order of the sort
+		{`{"x":1}`, `{"b":-1,"a":1}`, `{ x: 1, b: -1, a: 1 }`},
+	}
+	for i, c := range cases {
+		if s := suggestIndex(c.query, c.sort).String(); s != c.expected {
+			t.Errorf("case %d: expected %s, got %s", i, c.expected, s)
+		}
+	}
+}
+
+func TestAdvisorSortOrder(t *testing.T) {
+	line := `2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { query: { status: "A" }, orderby: { z: -1, a: 1 } } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:1000 nreturned:2 reslen:40 locks:{} 100ms`
+	e, err := parser.ParseLogEntry(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAdvisor()
+	a.add(e)
+	scans := a.report(10)[0].CollectionScans
+	if len(scans) != 1 || scans[0].Suggestion != `{ status: 1, z: -1, a: 1 }` {
+		t.Errorf("expected { status: 1, z: -1, a: 1 }, got %+v", scans)
+	}
+}
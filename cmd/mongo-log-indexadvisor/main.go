@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+var (
+	flagFormat   = flag.String("format", "table", "output format: table or json")
+	flagMinRatio = flag.Float64("min-ratio", 10, "only report collection scans that examined at least this many documents per document returned")
+)
+
+func main() {
+	paths := loginput.ParseFlags("[logfile ...]")
+
+	var report func(io.Writer, []*namespaceReport) error
+	switch *flagFormat {
+	case "table":
+		report = writeTable
+	case "json":
+		report = writeJSON
+	default:
+		loginput.Fatal("unknown format:", *flagFormat)
+	}
+
+	a := newAdvisor()
+	loginput.MustCollect(paths, a.add)
+
+	if err := report(os.Stdout, a.report(*flagMinRatio)); err != nil {
+		loginput.Fatal("error writing report:", err)
+	}
+}
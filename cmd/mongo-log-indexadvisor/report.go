@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+func writeTable(w io.Writer, namespaces []*namespaceReport) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, r := range namespaces {
+		if i != 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintln(tw, r.Namespace)
+		if len(r.Indexes) != 0 {
+			fmt.Fprintln(tw, "  index\tcount")
+			for _, u := range r.Indexes {
+				fmt.Fprintf(tw, "  %s\t%d\n", u.Index, u.Count)
+			}
+		}
+		if len(r.CollectionScans) != 0 {
+			fmt.Fprintln(tw, "  collection scan\tcount\texamined\treturned\tratio\tsuggested index")
+			for _, s := range r.CollectionScans {
+				suggestion := s.Suggestion
+				if suggestion == "" {
+					suggestion = "-"
+				}
+				fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%.1f\t%s\n",
+					s.Pattern, s.Count, s.Examined, s.Returned, s.Ratio, suggestion)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, namespaces []*namespaceReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(namespaces)
+}
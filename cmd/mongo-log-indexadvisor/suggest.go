@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// rangeOperators are the operators that match a range of values rather than one.
+// Other operators, such as $in and $elemMatch, are treated as equality matches.
+var rangeOperators = map[string]bool{
+	"$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$ne": true, "$nin": true, "$not": true, "$regex": true, "$exists": true, "$type": true,
+}
+
+// suggestIndex proposes a compound index for a query shape and sort shape following
+// the equality, sort, range rule: fields matched exactly come first, then the sort
+// fields, so that the index returns documents in order, then fields matched by range.
+// It returns nil if there's nothing to index, such as for an $or.
+//
+// The sort fields keep their order, which the index must match.  The order of the
+// filter's fields isn't logged, so equality and range fields are in alphabetical
+// order.
+func suggestIndex(queryShape, sortShape string) parser.KeyPattern {
+	var equality, ranges []string
+	if queryShape != "" {
+		var query map[string]interface{}
+		if err := json.Unmarshal([]byte(queryShape), &query); err != nil {
+			return nil
+		}
+		classifyFields(query, &equality, &ranges)
+	}
+	var sortKeys parser.KeyPattern
+	if sortShape != "" {
+		var err error
+		if sortKeys, err = decodeSortShape(sortShape); err != nil {
+			return nil
+		}
+	}
+
+	var k parser.KeyPattern
+	seen := make(map[string]bool)
+	add := func(field string, value interface{}) {
+		if !seen[field] {
+			seen[field] = true
+			k = append(k, parser.IndexKey{Field: field, Value: value})
+		}
+	}
+	sort.Strings(equality)
+	for _, field := range equality {
+		add(field, float64(1))
+	}
+	for _, key := range sortKeys {
+		dir, ok := key.Value.(float64)
+		if !ok {
+			// { $meta: "textScore" } and the like can't come from an index
+			continue
+		}
+		add(key.Field, dir)
+	}
+	sort.Strings(ranges)
+	for _, field := range ranges {
+		add(field, float64(1))
+	}
+	return k
+}
+
+// classifyFields adds the fields of a query shape to equality or ranges.  The
+// clauses of an $and are the query's too, but those of an $or or $nor aren't, since
+// they'd need an index each.
+func classifyFields(query map[string]interface{}, equality, ranges *[]string) {
+	for field, value := range query {
+		if field == "$and" {
+			clauses, _ := value.([]interface{})
+			for _, clause := range clauses {
+				if doc, ok := clause.(map[string]interface{}); ok {
+					classifyFields(doc, equality, ranges)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(field, "$") {
+			continue
+		}
+		if isRange(value) {
+			*ranges = append(*ranges, field)
+		} else {
+			*equality = append(*equality, field)
+		}
+	}
+}
+
+func isRange(value interface{}) bool {
+	doc, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for op := range doc {
+		if rangeOperators[op] {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSortShape decodes a sort shape, keeping its fields in order
+func decodeSortShape(sortShape string) (parser.KeyPattern, error) {
+	dec := json.NewDecoder(strings.NewReader(sortShape))
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("invalid sort shape '%s'", sortShape)
+	}
+	var k parser.KeyPattern
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		field, _ := t.(string)
+		var value interface{}
+		if err = dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		k = append(k, parser.IndexKey{Field: field, Value: value})
+	}
+	return k, nil
+}
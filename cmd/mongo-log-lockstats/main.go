@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+var (
+	flagFormat = flag.String("format", "table", "output format: table or json")
+	flagLimit  = flag.Int("n", 0, "only report the n namespaces with the most time spent waiting for locks (0 for all)")
+)
+
+func main() {
+	paths := loginput.ParseFlags("[logfile ...]")
+
+	var report func(io.Writer, []*lockStats) error
+	switch *flagFormat {
+	case "table":
+		report = writeTable
+	case "json":
+		report = writeJSON
+	default:
+		loginput.Fatal("unknown format:", *flagFormat)
+	}
+
+	stats := newStatsCollector()
+	loginput.MustCollect(paths, stats.add)
+
+	namespaces := stats.sorted()
+	if *flagLimit > 0 && len(namespaces) > *flagLimit {
+		namespaces = namespaces[:*flagLimit]
+	}
+	if err := report(os.Stdout, namespaces); err != nil {
+		loginput.Fatal("error writing report:", err)
+	}
+}
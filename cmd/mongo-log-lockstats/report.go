@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func writeTable(w io.Writer, namespaces []*lockStats) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "namespace\toperations\tacquired\twaits\twait(ms)\tlocked(ms)\twaited for")
+	for _, g := range namespaces {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%s\n",
+			g.Namespace, g.Operations, g.AcquireCount, g.WaitCount,
+			float64(g.WaitMicros)/1000, float64(g.LockedMicros)/1000,
+			strings.Join(g.Resources, ","))
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, namespaces []*lockStats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(namespaces)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// lockStats are the locks taken by the operations on one namespace, summed over
+// every resource and mode
+type lockStats struct {
+	Namespace    string `json:"namespace"`
+	Operations   int    `json:"operations"`
+	AcquireCount int64  `json:"acquire_count"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitMicros   int64  `json:"wait_micros"`
+	// LockedMicros is the time locks were held, which only lines from before 3.0 have
+	LockedMicros int64 `json:"locked_micros"`
+	// Resources are the resources that were waited for, most waited for first
+	Resources []string `json:"resources,omitempty"`
+
+	resourceWaits map[string]int64
+}
+
+type statsCollector struct {
+	namespaces map[string]*lockStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{namespaces: make(map[string]*lockStats)}
+}
+
+func (c *statsCollector) add(e *parser.LogEntry) {
+	if e.Operation == "" || e.Locks == nil {
+		return
+	}
+
+	ns := e.Namespace.String()
+	g, ok := c.namespaces[ns]
+	if !ok {
+		g = &lockStats{Namespace: ns, resourceWaits: make(map[string]int64)}
+		c.namespaces[ns] = g
+	}
+	g.Operations++
+	for resource, modes := range e.Locks {
+		for _, stats := range modes {
+			g.AcquireCount += stats.AcquireCount
+			g.WaitCount += stats.WaitCount
+			g.WaitMicros += stats.WaitMicros
+			g.LockedMicros += stats.LockedMicros
+			if stats.WaitCount != 0 || stats.WaitMicros != 0 {
+				g.resourceWaits[resource] += stats.WaitMicros
+			}
+		}
+	}
+}
+
+// sorted computes the statistics of every namespace and returns them by descending
+// time spent waiting for locks, then holding them
+func (c *statsCollector) sorted() []*lockStats {
+	rv := make([]*lockStats, 0, len(c.namespaces))
+	for _, g := range c.namespaces {
+		g.compute()
+		rv = append(rv, g)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].WaitMicros != rv[j].WaitMicros {
+			return rv[i].WaitMicros > rv[j].WaitMicros
+		}
+		if rv[i].LockedMicros != rv[j].LockedMicros {
+			return rv[i].LockedMicros > rv[j].LockedMicros
+		}
+		return rv[i].Namespace < rv[j].Namespace
+	})
+	return rv
+}
+
+func (g *lockStats) compute() {
+	g.Resources = g.Resources[:0]
+	for resource := range g.resourceWaits {
+		g.Resources = append(g.Resources, resource)
+	}
+	sort.Slice(g.Resources, func(i, j int) bool {
+		wi, wj := g.resourceWaits[g.Resources[i]], g.resourceWaits[g.Resources[j]]
+		if wi != wj {
+			return wi > wj
+		}
+		return g.Resources[i] < g.Resources[j]
+	})
+}
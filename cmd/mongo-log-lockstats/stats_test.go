@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+func TestStatsCollector(t *testing.T) {
+	lines := []string{
+		`2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: 1 } update: { $set: { a: 1 } } nMatched:1 nModified:1 numYields:0 locks:{ Global: { acquireCount: { w: 2 } }, Database: { acquireCount: { w: 2 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 3000 } } } 4ms`,
+		`2015-03-04T11:31:46.116-0800 I WRITE    [conn3] update test.foo query: { _id: 2 } update: { $set: { a: 1 } } nMatched:1 nModified:1 numYields:0 locks:{ Global: { acquireCount: { w: 2 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 500 } }, Database: { acquireCount: { w: 2 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 1000 } } } 4ms`,
+		`2015-03-04T11:31:47.116-0800 I QUERY    [conn4] query test.bar query: { a: 1 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:10 nreturned:0 reslen:40 locks:{ Global: { acquireCount: { r: 2 } } } 1ms`,
+		`2014-06-02T11:31:45.116-0400 [conn5] query test.baz query: { a: 1 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:10 keyUpdates:0 numYields:0 locks(micros) r:120 nreturned:1 reslen:40 0ms`,
+		`2015-03-04T11:31:48.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}
+	c := newStatsCollector()
+	if failed, err := loginput.CollectReader(strings.NewReader(strings.Join(lines, "\n")), c.add); failed != 0 || err != nil {
+		t.Fatalf("expected every line to parse, got %d failed and %v", failed, err)
+	}
+
+	namespaces := c.sorted()
+	if len(namespaces) != 3 {
+		t.Fatalf("expected 3 namespaces, got %d", len(namespaces))
+	}
+	g := namespaces[0]
+	if g.Namespace != "test.foo" || g.Operations != 2 || g.AcquireCount != 8 || g.WaitCount != 3 || g.WaitMicros != 4500 {
+		t.Errorf("unexpected stats for first namespace: %+v", g)
+	}
+	if len(g.Resources) != 2 || g.Resources[0] != "Database" || g.Resources[1] != "Global" {
+		t.Errorf("unexpected resources for first namespace: %v", g.Resources)
+	}
+	if g = namespaces[1]; g.Namespace != "test.baz" || g.LockedMicros != 120 {
+		t.Errorf("unexpected stats for second namespace: %+v", g)
+	}
+	if g = namespaces[2]; g.Namespace != "test.bar" || g.AcquireCount != 2 || g.WaitMicros != 0 || len(g.Resources) != 0 {
+		t.Errorf("unexpected stats for third namespace: %+v", g)
+	}
+}
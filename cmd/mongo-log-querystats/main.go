@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+var (
+	flagFormat = flag.String("format", "table", "output format: table or json")
+	flagLimit  = flag.Int("n", 0, "only report the n groups with the highest total time (0 for all)")
+)
+
+func main() {
+	paths := loginput.ParseFlags("[logfile ...]")
+
+	var report func(io.Writer, []*queryStats) error
+	switch *flagFormat {
+	case "table":
+		report = writeTable
+	case "json":
+		report = writeJSON
+	default:
+		loginput.Fatal("unknown format:", *flagFormat)
+	}
+
+	stats := newStatsCollector()
+	loginput.MustCollect(paths, stats.add)
+
+	groups := stats.sorted()
+	if *flagLimit > 0 && len(groups) > *flagLimit {
+		groups = groups[:*flagLimit]
+	}
+	if err := report(os.Stdout, groups); err != nil {
+		loginput.Fatal("error writing report:", err)
+	}
+}
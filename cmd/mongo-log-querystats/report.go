@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+func writeTable(w io.Writer, groups []*queryStats) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "namespace\toperation\tpattern\tcount\tmin(ms)\tmax(ms)\tmean(ms)\tp95(ms)\ttotal(ms)\tnscanned\tnreturned\tratio")
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.0f\t%.0f\t%.1f\t%.0f\t%.0f\t%d\t%d\t%.1f\n",
+			g.Namespace, g.Operation, g.Pattern, g.Count,
+			g.MinMS, g.MaxMS, g.MeanMS, g.P95MS, g.TotalMS,
+			g.NScanned, g.NReturned, g.ScanRatio)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, groups []*queryStats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(groups)
+}
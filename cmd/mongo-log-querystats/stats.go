@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// groupKey identifies the operations that are reported together
+type groupKey struct {
+	namespace string
+	operation string
+	pattern   string
+}
+
+// queryStats are the statistics for one group of operations
+type queryStats struct {
+	Namespace string  `json:"namespace"`
+	Operation string  `json:"operation"`
+	Pattern   string  `json:"pattern"`
+	Count     int     `json:"count"`
+	MinMS     float64 `json:"min_ms"`
+	MaxMS     float64 `json:"max_ms"`
+	MeanMS    float64 `json:"mean_ms"`
+	P95MS     float64 `json:"p95_ms"`
+	TotalMS   float64 `json:"total_ms"`
+	NScanned  int64   `json:"nscanned"`
+	NReturned int64   `json:"nreturned"`
+	// ScanRatio is NScanned/NReturned, or NScanned when nothing was returned
+	ScanRatio float64 `json:"scan_ratio"`
+
+	durations []time.Duration
+}
+
+type statsCollector struct {
+	groups map[groupKey]*queryStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{groups: make(map[groupKey]*queryStats)}
+}
+
+func (c *statsCollector) add(e *parser.LogEntry) {
+	if e.Operation == "" {
+		return
+	}
+
+	key := groupKey{
+		namespace: e.Namespace.String(),
+		operation: operationName(e),
+		pattern:   e.QueryPattern(),
+	}
+	g, ok := c.groups[key]
+	if !ok {
+		g = &queryStats{Namespace: key.namespace, Operation: key.operation, Pattern: key.pattern}
+		c.groups[key] = g
+	}
+	g.durations = append(g.durations, e.Duration)
+	g.NScanned += e.NScanned
+	g.NReturned += e.NReturned
+}
+
+// sorted computes the statistics of every group and returns them by descending total time
+func (c *statsCollector) sorted() []*queryStats {
+	rv := make([]*queryStats, 0, len(c.groups))
+	for _, g := range c.groups {
+		g.compute()
+		rv = append(rv, g)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].TotalMS != rv[j].TotalMS {
+			return rv[i].TotalMS > rv[j].TotalMS
+		}
+		return rv[i].Count > rv[j].Count
+	})
+	return rv
+}
+
+func (g *queryStats) compute() {
+	sort.Slice(g.durations, func(i, j int) bool { return g.durations[i] < g.durations[j] })
+
+	g.Count = len(g.durations)
+	var total time.Duration
+	for _, d := range g.durations {
+		total += d
+	}
+	g.TotalMS = ms(total)
+	g.MinMS = ms(g.durations[0])
+	g.MaxMS = ms(g.durations[g.Count-1])
+	g.MeanMS = g.TotalMS / float64(g.Count)
+	g.P95MS = ms(percentile(g.durations, 95))
+
+	g.ScanRatio = float64(g.NScanned)
+	if g.NReturned != 0 {
+		g.ScanRatio /= float64(g.NReturned)
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// operationName reports commands by their command name, since from 3.2 on queries
+// are logged as find commands
+func operationName(e *parser.LogEntry) string {
+	if e.Operation == "command" && e.CommandType != "" {
+		return e.CommandType
+	}
+	return e.Operation
+}
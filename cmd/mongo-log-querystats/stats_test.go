@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+func TestStatsCollector(t *testing.T) {
+	lines := []string{
+		`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:100 nreturned:1 reslen:40 locks:{} 100ms`,
+		`2015-03-04T11:31:46.116-0800 I QUERY    [conn2] query test.foo query: { a: 2 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:100 nreturned:1 reslen:40 locks:{} 300ms`,
+		`2015-03-04T11:31:47.116-0800 I QUERY    [conn2] query test.foo query: { b: 2 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:10 nreturned:0 reslen:40 locks:{} 200ms`,
+		`2015-03-04T11:31:48.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}
+	c := newStatsCollector()
+	if failed, err := loginput.CollectReader(strings.NewReader(strings.Join(lines, "\n")), c.add); failed != 0 || err != nil {
+		t.Fatalf("expected every line to parse, got %d failed and %v", failed, err)
+	}
+
+	groups := c.sorted()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.Pattern != `{"a":1}` || g.Count != 2 || g.TotalMS != 400 || g.MinMS != 100 || g.MaxMS != 300 || g.MeanMS != 200 || g.P95MS != 300 {
+		t.Errorf("unexpected stats for first group: %+v", g)
+	}
+	if g.NScanned != 200 || g.NReturned != 2 || g.ScanRatio != 100 {
+		t.Errorf("unexpected counters for first group: %+v", g)
+	}
+	if g = groups[1]; g.Pattern != `{"b":1}` || g.ScanRatio != 10 {
+		t.Errorf("unexpected stats for second group: %+v", g)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/toshok/mongologtools/cmd/internal/loginput"
+)
+
+var flagFormat = flag.String("format", "table", "output format: table or json")
+
+func main() {
+	nodes := loginput.ParseFlags("logfile ...", "The logs of the members of a replica set are merged, and each is a column of the table.")
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var report func(io.Writer, []string, []*event) error
+	switch *flagFormat {
+	case "table":
+		report = writeTable
+	case "json":
+		report = writeJSON
+	default:
+		loginput.Fatal("unknown format:", *flagFormat)
+	}
+
+	var rs []io.Reader
+	for _, path := range nodes {
+		f, err := loginput.Open(path)
+		if err != nil {
+			loginput.Fatal("error opening input:", err)
+		}
+		defer f.Close()
+		rs = append(rs, f)
+	}
+
+	events, parseErrs, err := timeline(rs, nodes)
+	if err != nil {
+		loginput.Fatal("error reading input:", err)
+	}
+	loginput.ReportFailed(parseErrs)
+	if err = report(os.Stdout, nodes, events); err != nil {
+		loginput.Fatal("error writing report:", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// timeLayout has a fixed width so that the table lines up
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// writeTable writes the events with a column per node, so that what each node saw
+// at the same time lines up
+func writeTable(w io.Writer, nodes []string, events []*event) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "time")
+	for _, node := range nodes {
+		fmt.Fprint(tw, "\t", node)
+	}
+	fmt.Fprintln(tw)
+	for _, e := range events {
+		fmt.Fprint(tw, e.Time.Format(timeLayout))
+		for _, node := range nodes {
+			fmt.Fprint(tw, "\t")
+			if node == e.Node {
+				fmt.Fprint(tw, e.describe())
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, nodes []string, events []*event) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
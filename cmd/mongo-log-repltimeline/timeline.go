@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// event is a replica set event on one node
+type event struct {
+	Time     time.Time `json:"time"`
+	Node     string    `json:"node"`
+	Event    string    `json:"event"`
+	OldState string    `json:"old_state,omitempty"`
+	NewState string    `json:"new_state,omitempty"`
+	Member   string    `json:"member,omitempty"`
+	Term     int64     `json:"term,omitempty"`
+}
+
+// describe is the event in a few words, as in "SECONDARY -> PRIMARY"
+func (e *event) describe() string {
+	var s string
+	switch e.Event {
+	case "state_change":
+		s = "-> " + e.NewState
+		if e.OldState != "" {
+			s = e.OldState + " " + s
+		}
+	case "member_state":
+		s = e.Member + " is " + e.NewState
+	case "election_start":
+		s = "election started"
+	case "election_won":
+		s = "election won"
+	default:
+		s = e.Event
+	}
+	if e.Term != 0 {
+		s += fmt.Sprintf(" (term %d)", e.Term)
+	}
+	return s
+}
+
+// timeline merges the logs of the nodes in rs, named by nodes, and returns their
+// replica set events in time order.  It also returns the number of lines that
+// couldn't be parsed.
+func timeline(rs []io.Reader, nodes []string) ([]*event, int, error) {
+	var scanners []*parser.Scanner
+	for _, r := range rs {
+		scanners = append(scanners, parser.NewScanner(r))
+	}
+	m := parser.NewMerger(scanners...)
+
+	var events []*event
+	parseErrs := 0
+	for {
+		entry, i, err := m.Next()
+		if err == io.EOF {
+			return events, parseErrs, nil
+		}
+		if _, ok := err.(*parser.ParseError); ok {
+			parseErrs++
+			continue
+		}
+		if err != nil {
+			return nil, parseErrs, err
+		}
+		if entry.Repl == nil {
+			continue
+		}
+		e := &event{
+			Time:     entry.Timestamp,
+			Node:     nodes[i],
+			Event:    entry.Repl.Type,
+			OldState: entry.Repl.OldState,
+			NewState: entry.Repl.NewState,
+			Member:   entry.Repl.Member,
+		}
+		if entry.Repl.Term >= 0 {
+			e.Term = entry.Repl.Term
+		}
+		events = append(events, e)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	rs := []io.Reader{
+		strings.NewReader(strings.Join([]string{
+			`2015-03-04T11:31:45.000Z I REPL     [ReplicationExecutor] transition to SECONDARY from PRIMARY`,
+			`2015-03-04T11:31:47.000Z I REPL     [ReplicationExecutor] Member db2:27017 is now in state PRIMARY`,
+		}, "\n")),
+		strings.NewReader(strings.Join([]string{
+			`2015-03-04T11:31:45.500Z I REPL     [ReplicationExecutor] dry election run succeeded, running for election in term 6`,
+			`2015-03-04T11:31:45.600Z I NETWORK  [conn3] end connection 10.0.0.5:53422 (1 connection now open)`,
+			`garbage line`,
+			`2015-03-04T11:31:46.000Z I REPL     [ReplicationExecutor] election succeeded, assuming primary role in term 6`,
+			`2015-03-04T11:31:46.000Z I REPL     [ReplicationExecutor] transition to PRIMARY from SECONDARY`,
+		}, "\n")),
+	}
+	nodes := []string{"db1", "db2"}
+	events, parseErrs, err := timeline(rs, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parseErrs != 1 {
+		t.Errorf("expected 1 unparseable line, got %d", parseErrs)
+	}
+
+	var out bytes.Buffer
+	if err = writeTable(&out, nodes, events); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		`time                      db1                   db2`,
+		`2015-03-04T11:31:45.000Z  PRIMARY -> SECONDARY  `,
+		`2015-03-04T11:31:45.500Z                        election started (term 6)`,
+		`2015-03-04T11:31:46.000Z                        election won (term 6)`,
+		`2015-03-04T11:31:46.000Z                        SECONDARY -> PRIMARY`,
+		`2015-03-04T11:31:47.000Z  db2:27017 is PRIMARY  `,
+	}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, out.String())
+	}
+}
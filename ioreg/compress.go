@@ -0,0 +1,38 @@
+package ioreg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+var ErrZstdNotSupported = errors.New("io: zstd compressed input is not supported")
+
+// decompress returns a reader for the decompressed content of r if it's gzip or
+// bzip2 compressed, and r itself otherwise.  The compression is detected from the
+// content rather than the file name, since rotated logs are renamed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, ErrZstdNotSupported
+	}
+	return br, nil
+}
@@ -0,0 +1,232 @@
+package ioreg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// fileio reads and writes a file, or stdin and stdout for the path "-".  Compressed
+// input is decompressed.  As an output its parameters are
+//
+//	mode    truncate (the default) replaces the file, append adds to it, and
+//	        exclusive fails if it already exists
+//	atomic  if true, output goes to a temporary file in the same directory that's
+//	        renamed into place when it's closed, so the file is either left as it was
+//	        or has the complete output.  Not with mode=append.
+//
+// The file is synced to disk when it's closed.
+type fileio struct {
+	path   string
+	mode   string
+	atomic bool
+}
+
+var ErrBadFileMode = errors.New("file: mode must be truncate, append or exclusive")
+
+func (f *fileio) Reader() (io.ReadCloser, error) {
+	if f.path == "-" {
+		r, err := decompress(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(r), nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// decompress returns its buffered reader for input that isn't compressed
+	if _, plain := r.(*bufio.Reader); plain {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			return &fileReader{readCloser{Reader: r, Closer: file}, file, info.Size()}, nil
+		}
+	}
+	return &readCloser{Reader: r, Closer: file}, nil
+}
+
+func (f *fileio) Writer() (io.WriteCloser, error) {
+	if f.path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+
+	if f.atomic {
+		if f.mode == "exclusive" {
+			if _, err := os.Lstat(f.path); err == nil {
+				return nil, &os.PathError{Op: "open", Path: f.path, Err: os.ErrExist}
+			}
+		}
+		dir, name := filepath.Split(f.path)
+		if dir == "" {
+			dir = "."
+		}
+		tmp, err := ioutil.TempFile(dir, "."+name+".tmp")
+		if err != nil {
+			return nil, err
+		}
+		if err = tmp.Chmod(0660); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		return &atomicFile{File: tmp, path: f.path, exclusive: f.mode == "exclusive"}, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch f.mode {
+	case "truncate":
+		flags |= os.O_TRUNC
+	case "append":
+		flags |= os.O_APPEND
+	case "exclusive":
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(f.path, flags, 0660)
+	if err != nil {
+		return nil, err
+	}
+	return &syncedFile{file}, nil
+}
+
+func newFileIO(path string, params url.Values) (*fileio, error) {
+	if err := CheckParams(params, "mode", "atomic"); err != nil {
+		return nil, err
+	}
+	f := &fileio{path: path, mode: params.Get("mode")}
+	switch f.mode {
+	case "":
+		f.mode = "truncate"
+	case "truncate", "append", "exclusive":
+	default:
+		return nil, ErrBadFileMode
+	}
+	if atomic := params.Get("atomic"); atomic != "" {
+		var err error
+		if f.atomic, err = strconv.ParseBool(atomic); err != nil {
+			return nil, fmt.Errorf("file: atomic must be true or false, not '%s'", atomic)
+		}
+	}
+	if f.atomic && f.mode == "append" {
+		return nil, errors.New("file: atomic can't be used with mode=append")
+	}
+	return f, nil
+}
+
+func init() {
+	RegisterSource("file", func(path string, params url.Values) (Source, error) {
+		if err := CheckParams(params); err != nil {
+			return nil, err
+		}
+		return &fileio{path: path}, nil
+	})
+	RegisterSink("file", func(path string, params url.Values) (Sink, error) {
+		return newFileIO(path, params)
+	})
+}
+
+// syncedFile syncs a file to disk before closing it
+type syncedFile struct {
+	*os.File
+}
+
+func (f *syncedFile) Close() error {
+	var err error
+	// devices such as /dev/stdout can't be synced
+	if info, serr := f.Stat(); serr == nil && info.Mode().IsRegular() {
+		err = f.File.Sync()
+	}
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// atomicFile is a temporary file that replaces path when it's closed, or is removed
+// if it's aborted
+type atomicFile struct {
+	*os.File
+	path      string
+	exclusive bool
+}
+
+func (f *atomicFile) Close() error {
+	err := f.File.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if f.exclusive {
+		// unlike a rename, a link fails if something has created the file since
+		err = os.Link(f.Name(), f.path)
+		os.Remove(f.Name())
+	} else if err = os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+	}
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+func (f *atomicFile) Abort() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// not every platform can sync a directory; the rename has happened regardless
+	d.Sync()
+	return nil
+}
+
+// readCloser reads from a decompressor and closes the file underneath it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// fileReader is the readCloser of an uncompressed regular file, which is
+// RandomAccess
+type fileReader struct {
+	readCloser
+	file *os.File
+	size int64
+}
+
+func (f *fileReader) ReadAt(p []byte, off int64) (int, error) {
+	return f.file.ReadAt(p, off)
+}
+
+func (f *fileReader) Size() int64 {
+	return f.size
+}
+
+// nopWriteCloser keeps stdout open
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package ioreg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.json")
+
+	write := func(params, content string) error {
+		sink, err := GetSink("file://" + path + params)
+		if err != nil {
+			return err
+		}
+		w, err := sink.Writer()
+		if err != nil {
+			return err
+		}
+		w.Write([]byte(content))
+		return w.Close()
+	}
+	expect := func(expected string) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Errorf("expected '%s'\nbut got '%s'", expected, content)
+		}
+	}
+
+	if err = write("", "a longer first run\n"); err != nil {
+		t.Fatal(err)
+	}
+	// a shorter second run doesn't leave the end of the first behind
+	if err = write("", "second\n"); err != nil {
+		t.Fatal(err)
+	}
+	expect("second\n")
+
+	if err = write("?mode=append", "third\n"); err != nil {
+		t.Fatal(err)
+	}
+	expect("second\nthird\n")
+
+	if err = write("?mode=exclusive", "fourth\n"); !os.IsExist(err) {
+		t.Errorf("expected the file to exist, got %v", err)
+	}
+	if err = write("?mode=exclusive&atomic=true", "fourth\n"); !os.IsExist(err) {
+		t.Errorf("expected the file to exist, got %v", err)
+	}
+
+	if err = write("?atomic=true", "fifth\n"); err != nil {
+		t.Fatal(err)
+	}
+	expect("fifth\n")
+
+	if _, err = GetSink("file://" + path + "?mode=append&atomic=true"); err == nil {
+		t.Errorf("expected atomic appends to be refused")
+	}
+	if _, err = GetSink("file://" + path + "?mode=overwrite"); err != ErrBadFileMode {
+		t.Errorf("expected ErrBadFileMode, got %v", err)
+	}
+}
+
+func TestAtomicFileAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.json")
+	if err = ioutil.WriteFile(path, []byte("previous\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := GetSink("file://" + path + "?atomic=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("partial"))
+	if err = w.(Aborter).Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := ioutil.ReadFile(path); string(content) != "previous\n" {
+		t.Errorf("expected the file to be left alone, got '%s'", content)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %v", names)
+	}
+}
+
+func TestFileRandomAccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mongod.log")
+	if err = ioutil.WriteFile(path, []byte("first\nsecond\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := GetSource("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := source.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ra, ok := r.(RandomAccess)
+	if !ok {
+		t.Fatalf("expected a plain file to be RandomAccess")
+	}
+	if ra.Size() != 13 {
+		t.Errorf("expected a size of 13, got %d", ra.Size())
+	}
+	buf := make([]byte, 6)
+	if _, err = ra.ReadAt(buf, 6); err != nil || string(buf) != "second" {
+		t.Errorf("expected to read 'second', got '%s' (%v)", buf, err)
+	}
+	// reading at an offset leaves the stream where it was
+	if content, _ := ioutil.ReadAll(r); string(content) != "first\nsecond\n" {
+		t.Errorf("unexpected content '%s'", content)
+	}
+}
@@ -0,0 +1,214 @@
+package ioreg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+// globio reads every file matching a pattern such as /var/log/mongodb/mongod.log*
+// as a single stream, oldest file first.  Compressed files are decompressed.
+type globio struct {
+	pattern string
+}
+
+func (g *globio) Reader() (io.ReadCloser, error) {
+	paths, err := filepath.Glob(g.pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("glob: no files match %s", g.pattern)
+	}
+	if paths, err = orderRotatedLogs(paths); err != nil {
+		return nil, err
+	}
+	return &multiFileReader{paths: paths}, nil
+}
+
+func init() {
+	RegisterSource("glob", func(path string, params url.Values) (Source, error) {
+		if err := CheckParams(params); err != nil {
+			return nil, err
+		}
+		return &globio{pattern: path}, nil
+	})
+}
+
+// orderRotatedLogs sorts log files oldest first.  That's by the timestamp of their
+// first line if every file has one, and by their rotation suffix otherwise.  ctime
+// timestamps have no year, which can't be inferred for a file on its own, so files
+// with those are sorted by modification time instead.
+func orderRotatedLogs(paths []string) ([]string, error) {
+	firsts := make(map[string]time.Time, len(paths))
+	byTimestamp := true
+	for _, path := range paths {
+		first, found, err := firstTimestamp(path)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			byTimestamp = false
+		}
+		if first.IsZero() && found {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			first = fi.ModTime()
+		}
+		firsts[path] = first
+	}
+
+	sorted := append([]string(nil), paths...)
+	if byTimestamp {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return firsts[sorted[i]].Before(firsts[sorted[j]])
+		})
+	} else {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return rotationLess(sorted[i], sorted[j])
+		})
+	}
+	return sorted, nil
+}
+
+// firstTimestamp returns the timestamp of the first parseable line of a log file,
+// with found false if there is none within the first few lines.  A ctime timestamp
+// is returned as the zero time.
+func firstTimestamp(path string) (first time.Time, found bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	for i := 0; i < 10 && s.Scan(); i++ {
+		entry, err := parser.ParseUndated(s.Text())
+		if err != nil {
+			continue
+		}
+		if _, ok := entry.Fields["timestamp"]; ok {
+			return entry.Timestamp, true, nil
+		}
+	}
+	return time.Time{}, false, s.Err()
+}
+
+var (
+	compressionSuffix = regexp.MustCompile(`\.(gz|bz2|zst)$`)
+	// logrotate appends .1, .2, ... with .1 the most recent
+	numberedSuffix = regexp.MustCompile(`\.([0-9]+)$`)
+	// mongod's logRotate appends the time of the rotation
+	datedSuffix = regexp.MustCompile(`\.([0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}-[0-9]{2}-[0-9]{2})$`)
+)
+
+// rotationRank orders rotated logs: numbered files from the highest number down,
+// then dated files by date, then the file currently being written.
+func rotationRank(path string) (group int, number int, date string) {
+	name := compressionSuffix.ReplaceAllString(filepath.Base(path), "")
+	if m := datedSuffix.FindStringSubmatch(name); m != nil {
+		return 1, 0, m[1]
+	}
+	if m := numberedSuffix.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return 0, -n, ""
+	}
+	return 2, 0, ""
+}
+
+func rotationLess(a, b string) bool {
+	ag, an, ad := rotationRank(a)
+	bg, bn, bd := rotationRank(b)
+	switch {
+	case ag != bg:
+		return ag < bg
+	case an != bn:
+		return an < bn
+	case ad != bd:
+		return ad < bd
+	}
+	return a < b
+}
+
+// multiFileReader reads a list of files one after the other, opening each one only
+// when the previous one is exhausted
+type multiFileReader struct {
+	paths []string
+
+	current  io.Reader
+	file     *os.File
+	lastByte byte
+}
+
+func (m *multiFileReader) Read(p []byte) (int, error) {
+	for {
+		if m.current == nil {
+			if len(m.paths) == 0 {
+				return 0, io.EOF
+			}
+			if err := m.open(m.paths[0]); err != nil {
+				return 0, err
+			}
+			m.paths = m.paths[1:]
+		}
+
+		n, err := m.current.Read(p)
+		if n > 0 {
+			m.lastByte = p[n-1]
+			return n, nil
+		}
+		if err == io.EOF {
+			m.file.Close()
+			m.current, m.file = nil, nil
+			// keep the last line of a file from running into the first line of the next
+			if m.lastByte != '\n' && m.lastByte != 0 && len(p) > 0 {
+				p[0], m.lastByte = '\n', '\n'
+				return 1, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (m *multiFileReader) Close() error {
+	m.paths = nil
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.current, m.file = nil, nil
+	return err
+}
+
+func (m *multiFileReader) open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	r, err := decompress(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	m.file, m.current = f, r
+	return nil
+}
@@ -0,0 +1,130 @@
+package ioreg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logs := map[string]string{
+		"mongod.log.2.gz": "2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] first\n",
+		"mongod.log.1":    "2015-03-05T11:31:45.116-0800 I NETWORK  [initandlisten] second",
+		"mongod.log":      "2015-03-06T11:31:45.116-0800 I NETWORK  [initandlisten] third\n",
+	}
+	for name, content := range logs {
+		data := []byte(content)
+		if strings.HasSuffix(name, ".gz") {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(data)
+			zw.Close()
+			data = buf.Bytes()
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input, err := GetSource("glob://" + filepath.Join(dir, "mongod.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := input.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := logs["mongod.log.2.gz"] + logs["mongod.log.1"] + "\n" + logs["mongod.log"]
+	if string(content) != expected {
+		t.Errorf("expected '%s'\nbut got '%s'", expected, content)
+	}
+}
+
+func TestGlobReaderCtime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the year of ctime timestamps isn't known, so the files are read in the order
+	// they were last written
+	logs := []struct {
+		name, content string
+	}{
+		{"mongod-b.log", "Wed Dec 31 23:59:59.000 [initandlisten] first\n"},
+		{"mongod-a.log", "Thu Jan  1 00:00:01.000 [initandlisten] second\n"},
+	}
+	modified := time.Now().Add(-time.Hour)
+	for _, log := range logs {
+		path := filepath.Join(dir, log.name)
+		if err = ioutil.WriteFile(path, []byte(log.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		modified = modified.Add(time.Minute)
+	}
+
+	input, err := GetSource("glob://" + filepath.Join(dir, "mongod-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := input.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := logs[0].content + logs[1].content
+	if string(content) != expected {
+		t.Errorf("expected '%s'\nbut got '%s'", expected, content)
+	}
+}
+
+func TestRotationOrder(t *testing.T) {
+	paths := []string{
+		"mongod.log",
+		"mongod.log.1",
+		"mongod.log.2015-03-05T11-31-45.gz",
+		"mongod.log.10.bz2",
+		"mongod.log.2015-03-04T11-31-45",
+		"mongod.log.2.gz",
+	}
+	sorted := append([]string(nil), paths...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return rotationLess(sorted[i], sorted[j])
+	})
+	expected := []string{
+		"mongod.log.10.bz2",
+		"mongod.log.2.gz",
+		"mongod.log.1",
+		"mongod.log.2015-03-04T11-31-45",
+		"mongod.log.2015-03-05T11-31-45.gz",
+		"mongod.log",
+	}
+	if strings.Join(sorted, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v\nbut got %v", expected, sorted)
+	}
+}
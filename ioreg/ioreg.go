@@ -0,0 +1,156 @@
+// Package ioreg is a registry of the sources log lines are read from and the sinks
+// parsed entries are written to, addressed by URLs such as file:///var/log/mongod.log
+// or glob:///var/log/mongodb/mongod.log*.  Schemes register a constructor for a
+// Source, a Sink or both, which gets the rest of the URL and its query parameters.
+package ioreg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Source is something log lines can be read from
+type Source interface {
+	Reader() (io.ReadCloser, error)
+}
+
+// Sink is something output can be written to
+type Sink interface {
+	Writer() (io.WriteCloser, error)
+}
+
+// Aborter is implemented by the writers of sinks that can discard what was written
+// to them, such as a file written atomically.  Abort is called in place of Close
+// when the output is incomplete.
+type Aborter interface {
+	Abort() error
+}
+
+// Checkpointer is implemented by the readers of sources that can save how far their
+// input has been handled, so that a restart picks up from there, such as tail://.
+// Commit records that everything up to offset, counted from the first byte Read
+// returned, has been handled for good, i.e. written and flushed.  It can be called
+// while a Read is in progress.
+type Checkpointer interface {
+	Commit(offset int64) error
+}
+
+// RandomAccess is implemented by the readers of sources that are uncompressed
+// regular files, so that they can be searched rather than read from the start.
+// ReadAt doesn't move the position Read reads from.
+type RandomAccess interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// InitSource returns the Source for path, the host and path of the URL, configured
+// with the URL's query parameters
+type InitSource func(path string, params url.Values) (Source, error)
+
+// InitSink returns the Sink for path, the host and path of the URL, configured with
+// the URL's query parameters
+type InitSink func(path string, params url.Values) (Sink, error)
+
+var (
+	ErrAlreadyRegistered = errors.New("io: already registered")
+	ErrNotRegistered     = errors.New("io: not registered")
+	// ErrCredentials is returned for URLs with a user or password whose scheme
+	// doesn't authenticate with them
+	ErrCredentials = errors.New("io: credentials in URLs are not supported")
+)
+
+var (
+	sources = map[string]InitSource{}
+	sinks   = map[string]InitSink{}
+	// credentialed are the schemes whose URLs can carry a user and password
+	credentialed = map[string]bool{}
+)
+
+// AllowCredentials lets the URLs of scheme carry a user and password, for a source
+// or sink that authenticates with them.  The path its constructor gets then starts
+// with the URL's escaped userinfo, as in user:password@host/path.
+func AllowCredentials(scheme string) {
+	credentialed[scheme] = true
+}
+
+func RegisterSource(scheme string, initFn InitSource) error {
+	if _, ok := sources[scheme]; ok {
+		return ErrAlreadyRegistered
+	}
+	sources[scheme] = initFn
+	return nil
+}
+
+func RegisterSink(scheme string, initFn InitSink) error {
+	if _, ok := sinks[scheme]; ok {
+		return ErrAlreadyRegistered
+	}
+	sinks[scheme] = initFn
+	return nil
+}
+
+func GetSource(source string) (Source, error) {
+	scheme, path, params, err := parseURL(source)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := sources[scheme]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return fn(path, params)
+}
+
+func GetSink(sink string) (Sink, error) {
+	scheme, path, params, err := parseURL(sink)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := sinks[scheme]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return fn(path, params)
+}
+
+func parseURL(s string) (scheme, path string, params url.Values, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", nil, err
+	}
+	path = u.Host + u.Path
+	if u.User != nil {
+		if !credentialed[u.Scheme] {
+			return "", "", nil, ErrCredentials
+		}
+		path = u.User.String() + "@" + path
+	}
+	return u.Scheme, path, u.Query(), nil
+}
+
+// CheckParams returns an error naming the query parameters that aren't among known,
+// so that a misspelled option isn't silently ignored
+func CheckParams(params url.Values, known ...string) error {
+	var unknown []string
+	for name := range params {
+		found := false
+		for _, k := range known {
+			if name == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("io: unknown parameter(s) %s", strings.Join(unknown, ", "))
+}
@@ -0,0 +1,258 @@
+package ioreg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	tailPollInterval = 250 * time.Millisecond
+	// the start of a file identifies it across restarts, since a rotated log is
+	// replaced by a new file with a different first line
+	tailFingerprintSize = 1024
+)
+
+// tailio follows a log file as mongod writes it, like tail -F.  It reopens the file
+// when logrotate moves it away or truncates it.  Its reader is a Checkpointer: how
+// far the lines have been handled is saved in a checkpoint file, <path>.offset
+// unless the checkpoint parameter names another, when it's committed and on Close,
+// so that a restart picks up where the last run stopped.
+type tailio struct {
+	path       string
+	checkpoint string
+}
+
+func (t *tailio) Reader() (io.ReadCloser, error) {
+	return newTailReader(t.path, t.checkpoint, tailPollInterval)
+}
+
+func init() {
+	RegisterSource("tail", func(path string, params url.Values) (Source, error) {
+		if err := CheckParams(params, "checkpoint"); err != nil {
+			return nil, err
+		}
+		checkpoint := params.Get("checkpoint")
+		if checkpoint == "" {
+			checkpoint = path + ".offset"
+		}
+		return &tailio{path: path, checkpoint: checkpoint}, nil
+	})
+}
+
+// tailCheckpoint is what's saved in the checkpoint file
+type tailCheckpoint struct {
+	Offset      int64  `json:"offset"`
+	Fingerprint string `json:"fingerprint"`
+	// FingerprintSize is how much of the start of the file Fingerprint covers,
+	// which is less than tailFingerprintSize for small files
+	FingerprintSize int64 `json:"fingerprint_size"`
+}
+
+type tailReader struct {
+	path           string
+	checkpointPath string
+	poll           time.Duration
+
+	// mu guards the rest against a Commit during a Read
+	mu     sync.Mutex
+	f      *os.File
+	info   os.FileInfo
+	offset int64
+	// read is how much Read has returned in all.  The current file was opened when
+	// read was opened, at its offset start, which maps committed stream offsets to
+	// offsets in the file.
+	read, opened, start int64
+	// committed is the offset in the current file up to which the lines have been
+	// committed, which is what gets checkpointed
+	committed int64
+	saved     int64
+}
+
+func newTailReader(path, checkpointPath string, poll time.Duration) (*tailReader, error) {
+	t := &tailReader{path: path, checkpointPath: checkpointPath, poll: poll}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+
+	cp, err := t.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil && cp.Offset <= t.info.Size() {
+		fingerprint, err := t.fingerprint(cp.FingerprintSize)
+		if err != nil {
+			return nil, err
+		}
+		if fingerprint == cp.Fingerprint {
+			if _, err = t.f.Seek(cp.Offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			t.offset, t.start, t.committed = cp.Offset, cp.Offset, cp.Offset
+		}
+	}
+	return t, nil
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.readOnce(p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+		// we've caught up with mongod
+		time.Sleep(t.poll)
+	}
+}
+
+// readOnce reads what there is, checking for rotation when there's nothing
+func (t *tailReader) readOnce(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, err := t.f.Read(p)
+	if n > 0 {
+		t.offset += int64(n)
+		t.read += int64(n)
+		return n, nil
+	}
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return 0, t.checkRotation()
+}
+
+// Commit saves the checkpoint at offset.  Offsets in a file that has since been
+// rotated away are ignored, since the checkpoint is for the current file.
+func (t *tailReader) Commit(offset int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if offset < t.opened {
+		return nil
+	}
+	t.committed = t.start + offset - t.opened
+	return t.saveCheckpoint()
+}
+
+// Close saves the checkpoint and closes the file
+func (t *tailReader) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := t.saveCheckpoint()
+	if cerr := t.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// checkRotation reopens the file if it was moved away and replaced, and starts over
+// at the beginning if it was truncated
+func (t *tailReader) checkRotation() error {
+	info, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		// moved away, and the new one isn't there yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(t.info, info) {
+		// finish whatever mongod wrote to the old file before it moved on
+		if old, err := t.f.Stat(); err == nil && old.Size() > t.offset {
+			return nil
+		}
+		t.f.Close()
+		return t.open()
+	}
+	if info.Size() < t.offset {
+		if _, err = t.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.restart()
+	}
+	return nil
+}
+
+func (t *tailReader) open() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	t.f, t.info = f, info
+	t.restart()
+	return nil
+}
+
+// restart starts reading the current file from its beginning
+func (t *tailReader) restart() {
+	t.offset, t.opened, t.start, t.committed = 0, t.read, 0, 0
+	// the checkpoint saved is for another file
+	t.saved = -1
+}
+
+// fingerprint hashes the first size bytes of the file
+func (t *tailReader) fingerprint(size int64) (string, error) {
+	buf := make([]byte, size)
+	n, err := t.f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	sum := sha1.Sum(buf[:n])
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (t *tailReader) loadCheckpoint() (*tailCheckpoint, error) {
+	buf, err := ioutil.ReadFile(t.checkpointPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp tailCheckpoint
+	if err = json.Unmarshal(buf, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint writes the checkpoint to a temporary file and renames it into
+// place, so a crash never leaves a partial checkpoint behind
+func (t *tailReader) saveCheckpoint() error {
+	if t.committed == t.saved {
+		return nil
+	}
+
+	size := t.committed
+	if size > tailFingerprintSize {
+		size = tailFingerprintSize
+	}
+	fingerprint, err := t.fingerprint(size)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(tailCheckpoint{Offset: t.committed, Fingerprint: fingerprint, FingerprintSize: size})
+	if err != nil {
+		return err
+	}
+	tmp := t.checkpointPath + ".tmp"
+	if err = ioutil.WriteFile(tmp, buf, 0660); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, t.checkpointPath); err != nil {
+		return err
+	}
+	t.saved = t.committed
+	return nil
+}
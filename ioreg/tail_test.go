@@ -0,0 +1,109 @@
+package ioreg
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTailReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mongod.log")
+	checkpoint := filepath.Join(dir, "mongod.log.offset")
+
+	appendLine := func(line string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(line + "\n")
+		f.Close()
+	}
+	// follow reads lines, with the offset just past each
+	type tailLine struct {
+		text   string
+		offset int64
+	}
+	follow := func() (*tailReader, <-chan tailLine) {
+		r, err := newTailReader(path, checkpoint, 10*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := make(chan tailLine)
+		go func() {
+			var offset int64
+			s := bufio.NewScanner(r)
+			s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+				advance, token, err := bufio.ScanLines(data, atEOF)
+				offset += int64(advance)
+				return advance, token, err
+			})
+			for s.Scan() {
+				lines <- tailLine{s.Text(), offset}
+			}
+		}()
+		return r, lines
+	}
+	// expect reads a line and commits it
+	expect := func(r *tailReader, lines <-chan tailLine, expected string, commit bool) {
+		select {
+		case line := <-lines:
+			if line.text != expected {
+				t.Fatalf("expected '%s' but got '%s'", expected, line.text)
+			}
+			if !commit {
+				return
+			}
+			if err := r.Commit(line.offset); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for '%s'", expected)
+		}
+	}
+
+	appendLine("first")
+	r, lines := follow()
+	expect(r, lines, "first", true)
+
+	// the file grows
+	appendLine("second")
+	expect(r, lines, "second", true)
+
+	// logrotate moves it away and mongod starts a new one
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLine("third line")
+	expect(r, lines, "third line", true)
+
+	// logrotate copytruncate
+	if err = ioutil.WriteFile(path, []byte("fourth\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect(r, lines, "fourth", true)
+
+	// a line that's read but never committed, say because the output it was written
+	// to wasn't flushed, is read again after a restart
+	appendLine("fifth")
+	expect(r, lines, "fifth", false)
+	r, lines = follow()
+	expect(r, lines, "fifth", true)
+	appendLine("sixth")
+	expect(r, lines, "sixth", true)
+
+	// Close saves what was committed
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	appendLine("seventh")
+	r, lines = follow()
+	expect(r, lines, "seventh", false)
+}
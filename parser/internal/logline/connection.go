@@ -0,0 +1,78 @@
+package logline
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the NETWORK messages logged as clients connect and disconnect, e.g.
+//
+//	connection accepted from 10.0.0.5:53422 #1234 (56 connections now open)
+//	end connection 10.0.0.5:53422 (55 connections now open)
+var (
+	connectionAcceptedRe = regexp.MustCompile(`^connection accepted from (\S+):(\d+) #(\d+) \((\d+) connections? now open\)`)
+	connectionEndedRe    = regexp.MustCompile(`^end connection (\S+):(\d+) \((\d+) connections? now open\)`)
+	connContextRe        = regexp.MustCompile(`^conn(\d+)$`)
+)
+
+// parseConnectionMessage adds the connection_event, client_ip, client_port,
+// connection_id and connections_open fields for a connection being accepted or
+// ended.  The connection id of an ended connection comes from the context.
+func parseConnectionMessage(fields map[string]interface{}, message string) {
+	if m := connectionAcceptedRe.FindStringSubmatch(message); m != nil {
+		setConnectionFields(fields, "accepted", m[1], m[2], m[3], m[4])
+	} else if m := connectionEndedRe.FindStringSubmatch(message); m != nil {
+		setConnectionFields(fields, "ended", m[1], m[2], connContextID(fields), m[3])
+	}
+}
+
+// parseJSONConnectionMessage is parseConnectionMessage for the "Connection accepted"
+// and "Connection ended" messages of >= 4.4, which carry the same in attr
+func parseJSONConnectionMessage(fields map[string]interface{}, msg string, attr map[string]interface{}) {
+	var event string
+	switch msg {
+	case "Connection accepted":
+		event = "accepted"
+	case "Connection ended":
+		event = "ended"
+	default:
+		return
+	}
+	remote, _ := attr["remote"].(string)
+	i := strings.LastIndex(remote, ":")
+	if i < 0 {
+		return
+	}
+	id := connContextID(fields)
+	if n, ok := attr["connectionId"].(float64); ok {
+		id = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	var count string
+	if n, ok := attr["connectionCount"].(float64); ok {
+		count = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	setConnectionFields(fields, event, remote[:i], remote[i+1:], id, count)
+}
+
+// connContextID returns the connection id of a "connNNN" context, if it is one
+func connContextID(fields map[string]interface{}) string {
+	context, _ := fields["context"].(string)
+	if m := connContextRe.FindStringSubmatch(context); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// setConnectionFields sets the connection fields, leaving out the numbers that
+// are empty
+func setConnectionFields(fields map[string]interface{}, event, ip, port, id, open string) {
+	fields["connection_event"] = event
+	// IPv6 addresses may be bracketed
+	fields["client_ip"] = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	for name, value := range map[string]string{"client_port": port, "connection_id": id, "connections_open": open} {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			fields[name] = n
+		}
+	}
+}
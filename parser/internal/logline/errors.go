@@ -0,0 +1,52 @@
+package logline
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// ParseError is returned by ParseLogLine for lines it can't parse
+type ParseError struct {
+	// Offset is the rune offset into the line where parsing failed
+	Offset int
+	// Expected describes what the parser was looking for at Offset, if known
+	Expected string
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return e.Msg
+}
+
+// fail returns a ParseError at the current position
+func (p *nonPegLogLineParser) fail(expected, msg string) error {
+	return p.failAt(p.position, expected, msg)
+}
+
+func (p *nonPegLogLineParser) failAt(position int, expected, msg string) error {
+	return &ParseError{Offset: position, Expected: expected, Msg: msg}
+}
+
+// asParseError turns errors from the helpers that don't know the position (e.g.
+// strconv) into a ParseError at the current position
+func (p *nonPegLogLineParser) asParseError(err error) error {
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	return p.fail("", err.Error())
+}
+
+// jsonParseError turns an error decoding a JSON log line into a ParseError
+func jsonParseError(input string, err error) error {
+	switch err := err.(type) {
+	case *ParseError:
+		return err
+	case *json.SyntaxError:
+		offset := int(err.Offset)
+		if offset > len(input) {
+			offset = len(input)
+		}
+		return &ParseError{Offset: utf8.RuneCountInString(input[:offset]), Msg: err.Error()}
+	}
+	return &ParseError{Msg: err.Error()}
+}
@@ -0,0 +1,155 @@
+package logline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonLogLine is the structured log line format written by MongoDB >= 4.4, e.g.
+//
+//	{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{...}}
+type jsonLogLine struct {
+	T    map[string]interface{}     `json:"t"`
+	S    string                     `json:"s"`
+	C    string                     `json:"c"`
+	ID   float64                    `json:"id"`
+	Ctx  string                     `json:"ctx"`
+	Msg  string                     `json:"msg"`
+	Attr map[string]json.RawMessage `json:"attr"`
+}
+
+// attributes of a slow operation line that map onto fields with a different name
+// in the classic log format
+var jsonAttrFields = map[string]string{
+	"type":           "operation",
+	"ns":             "namespace",
+	"durationMillis": "duration",
+}
+
+func isJSONLogLine(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "{")
+}
+
+func parseJSONLogLine(input string) (map[string]interface{}, KeyOrder, error) {
+	var line jsonLogLine
+	if err := json.Unmarshal([]byte(input), &line); err != nil {
+		return nil, nil, err
+	}
+
+	fields := make(map[string]interface{})
+	order := make(KeyOrder)
+
+	timestamp, err := jsonTimestamp(line.T)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields["timestamp"] = timestamp
+
+	// 4.4 uses D1-D5 for the debug levels
+	if line.S == "" {
+		return nil, nil, &ParseError{Expected: "severity", Msg: "missing severity"}
+	}
+	if fields["severity"], err = severityToString([]rune(line.S)[0]); err != nil {
+		return nil, nil, err
+	}
+	fields["component"] = line.C
+	fields["context"] = line.Ctx
+	fields["id"] = line.ID
+
+	attr := make(map[string]interface{}, len(line.Attr))
+	for key, raw := range line.Attr {
+		var value interface{}
+		if err = json.Unmarshal(raw, &value); err != nil {
+			return nil, nil, err
+		}
+		attr[key] = value
+	}
+
+	// only operations carry a namespace and a duration, everything else is kept as a
+	// message with its attributes attached
+	_, hasNS := attr["ns"]
+	_, hasDuration := attr["durationMillis"]
+	if !hasNS || !hasDuration {
+		fields["message"] = line.Msg
+		if len(attr) != 0 {
+			fields["attr"] = attr
+		}
+		parseJSONConnectionMessage(fields, line.Msg, attr)
+		parseJSONReplMessage(fields, line.Msg, attr)
+		return fields, order, nil
+	}
+
+	for key, value := range attr {
+		if name, ok := jsonAttrFields[key]; ok {
+			fields[name] = value
+			continue
+		}
+		switch key {
+		case "planSummary":
+			summary, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("unexpected type %T for planSummary", value)
+			}
+			var summaryOrder KeyOrder
+			if fields[key], summaryOrder, err = ParsePlanSummary(summary); err != nil {
+				return nil, nil, err
+			}
+			for path, keys := range summaryOrder {
+				order[path] = keys
+			}
+		case "locks":
+			if locks, ok := value.(map[string]interface{}); ok {
+				value = normalizeLocks(locks)
+			}
+			fields[key] = value
+		case "command":
+			fields[key] = value
+			if err = jsonKeyOrder(line.Attr[key], key, order); err != nil {
+				return nil, nil, err
+			}
+			// as in the text format, only commands are named by their first key; the
+			// command of an update or remove is the statement, such as { q: ..., u: ... }
+			if keys := order[key]; len(keys) != 0 && attr["type"] == "command" {
+				fields["command_type"] = keys[0]
+			}
+		default:
+			fields[key] = value
+		}
+	}
+	return fields, order, nil
+}
+
+// jsonTimestamp extracts the timestamp string from either relaxed ({"$date":"..."}) or
+// canonical ({"$date":{"$numberLong":"..."}}) extended JSON.
+func jsonTimestamp(t map[string]interface{}) (string, error) {
+	switch date := t["$date"].(type) {
+	case string:
+		return date, nil
+	case map[string]interface{}:
+		if n, ok := date["$numberLong"].(string); ok {
+			return n, nil
+		}
+	}
+	return "", &ParseError{Expected: "timestamp", Msg: "missing or invalid timestamp"}
+}
+
+// ParsePlanSummary parses the text of a planSummary, such as
+// "IXSCAN { a: 1 }, IXSCAN { b: 1 }", into the value of the planSummary field and
+// the key order of its index key patterns.  4.4 logs the text as the planSummary
+// attribute.
+func ParsePlanSummary(summary string) ([]interface{}, KeyOrder, error) {
+	// the trailing space keeps the identifier readers from running into the end of line
+	p := nonPegLogLineParser{Buffer: summary + " ", path: []string{"planSummary"}}
+	p.Init()
+	value, err := p.parsePlanSummary()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.eatWhitespace()
+	if p.lookahead(0) != endRune {
+		return nil, nil, p.fail("plan stage", fmt.Sprintf("invalid plan summary '%s'", summary))
+	}
+	stages, _ := value.([]interface{})
+	return stages, p.KeyOrder, nil
+}
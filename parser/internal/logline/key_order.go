@@ -0,0 +1,73 @@
+package logline
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// KeyOrder holds the keys of the documents of a parsed line in the order they were
+// logged, which decoding into maps loses.  Documents are keyed by their dotted path
+// from the fields, with array elements by index, e.g. "command.sort" or
+// "planSummary.0.IXSCAN".
+type KeyOrder map[string][]string
+
+// Keys returns the keys of doc, the document at path, in the order they were
+// logged.  They're sorted if the order isn't known.
+func (o KeyOrder) Keys(path string, doc map[string]interface{}) []string {
+	if keys, ok := o[path]; ok && len(keys) == len(doc) {
+		return keys
+	}
+	return sortedKeys(doc)
+}
+
+// jsonKeyOrder adds the key order of the documents in a JSON value at path to order
+func jsonKeyOrder(raw json.RawMessage, path string, order KeyOrder) error {
+	return walkJSONKeyOrder(json.NewDecoder(bytes.NewReader(raw)), path, order)
+}
+
+// walkJSONKeyOrder reads one value from dec
+func walkJSONKeyOrder(dec *json.Decoder, path string, order KeyOrder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t {
+	case json.Delim('{'):
+		var keys []string
+		for dec.More() {
+			if t, err = dec.Token(); err != nil {
+				return err
+			}
+			key, _ := t.(string)
+			keys = append(keys, key)
+			if err = walkJSONKeyOrder(dec, path+"."+key, order); err != nil {
+				return err
+			}
+		}
+		if len(keys) != 0 {
+			order[path] = keys
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err = walkJSONKeyOrder(dec, path+"."+strconv.Itoa(i), order); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	// the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+func sortedKeys(doc map[string]interface{}) []string {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
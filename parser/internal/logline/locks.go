@@ -0,0 +1,78 @@
+package logline
+
+// The locks field is normalized to a document keyed by resource and then mode, e.g.
+//
+//	{"Global":{"r":{"acquire_count":2}},"Database":{"r":{"acquire_count":1,"wait_count":1,"wait_micros":120}}}
+//
+// >= 3.0 logs the statistics first, as in
+//
+//	locks:{ Global: { acquireCount: { r: 2 } }, Database: { acquireCount: { r: 1 } } }
+//
+// and < 3.0 only logs the time each lock was held, as "locks(micros) r:86 w:120",
+// where r and w are the database lock and R and W the global lock.
+
+// lockStatNames are the names of the >= 3.0 lock statistics in the normalized locks
+var lockStatNames = map[string]string{
+	"acquireCount":        "acquire_count",
+	"acquireWaitCount":    "wait_count",
+	"timeAcquiringMicros": "wait_micros",
+	"deadlockCount":       "deadlock_count",
+}
+
+// legacyLockResources are the resources of the < 3.0 lock modes
+var legacyLockResources = map[string]string{
+	"r": "Database",
+	"w": "Database",
+	"R": "Global",
+	"W": "Global",
+}
+
+// normalizeLocks turns the >= 3.0 locks document, keyed by resource and then
+// statistic, into one keyed by resource and then mode
+func normalizeLocks(raw map[string]interface{}) map[string]interface{} {
+	locks := make(map[string]interface{})
+	for resource, value := range raw {
+		stats, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for stat, value := range stats {
+			name, ok := lockStatNames[stat]
+			if !ok {
+				name = stat
+			}
+			modes, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for mode, n := range modes {
+				setLockStat(locks, resource, mode, name, n)
+			}
+		}
+	}
+	return locks
+}
+
+// addLegacyLock adds the time a < 3.0 lock mode was held to the locks field
+func addLegacyLock(fields map[string]interface{}, mode string, micros float64) {
+	locks, ok := fields["locks"].(map[string]interface{})
+	if !ok {
+		locks = make(map[string]interface{})
+		fields["locks"] = locks
+	}
+	setLockStat(locks, legacyLockResources[mode], mode, "locked_micros", micros)
+}
+
+func setLockStat(locks map[string]interface{}, resource, mode, name string, value interface{}) {
+	modes, ok := locks[resource].(map[string]interface{})
+	if !ok {
+		modes = make(map[string]interface{})
+		locks[resource] = modes
+	}
+	stats, ok := modes[mode].(map[string]interface{})
+	if !ok {
+		stats = make(map[string]interface{})
+		modes[mode] = stats
+	}
+	stats[name] = value
+}
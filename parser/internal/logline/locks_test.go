@@ -0,0 +1,58 @@
+package logline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+func TestLocks(t *testing.T) {
+	cases := []struct{ input, expected string }{
+		// < 3.0
+		{
+			`2014-06-02T11:31:45.116-0400 [conn5] update test.foo query: { _id: 1 } update: { $set: { a: 1 } } nMatched:1 nModified:1 keyUpdates:0 numYields:0 locks(micros) W:12 w:1234 r:56 0ms`,
+			`{"Database":{"r":{"locked_micros":56},"w":{"locked_micros":1234}},"Global":{"W":{"locked_micros":12}}}`,
+		},
+		// >= 3.0
+		{
+			`2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: 1 } update: { $set: { a: 1 } } nMatched:1 nModified:1 keyUpdates:0 writeConflicts:0 numYields:0 locks:{ Global: { acquireCount: { r: 2, w: 2 } }, Database: { acquireCount: { w: 2 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 3021 } }, Collection: { acquireCount: { w: 1 } } } 4ms`,
+			`{"Collection":{"w":{"acquire_count":1}},"Database":{"w":{"acquire_count":2,"wait_count":1,"wait_micros":3021}},"Global":{"r":{"acquire_count":2},"w":{"acquire_count":2}}}`,
+		},
+		// >= 4.4
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"WRITE","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"remove","ns":"test.foo","locks":{"Global":{"acquireCount":{"w":1}},"Database":{"acquireCount":{"w":1},"acquireWaitCount":{"w":1},"timeAcquiringMicros":{"w":90}}},"durationMillis":105}}`,
+			`{"Database":{"w":{"acquire_count":1,"wait_count":1,"wait_micros":90}},"Global":{"w":{"acquire_count":1}}}`,
+		},
+	}
+	for i, c := range cases {
+		fields, err := logline.ParseLogLine(c.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		for _, mode := range []string{"r", "w", "R", "W"} {
+			if _, ok := fields[mode]; ok {
+				t.Errorf("case %d: unexpected top level field '%s'", i, mode)
+			}
+		}
+		buf, _ := json.Marshal(fields["locks"])
+		if string(buf) != c.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, c.expected, buf)
+		}
+	}
+}
+
+func TestLegacyLocksOnlyAfterMarker(t *testing.T) {
+	// w here is a field of its own, not the time the write lock was held
+	fields, err := logline.ParseLogLine(`2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: 1 } update: { $set: { a: 1 } } w:1 nMatched:1 nModified:1 locks:{ Global: { acquireCount: { w: 2 } } } 4ms`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["w"] != float64(1) {
+		t.Errorf("expected the w field to be kept, got %v", fields["w"])
+	}
+	buf, _ := json.Marshal(fields["locks"])
+	if expected := `{"Global":{"w":{"acquire_count":2}}}`; string(buf) != expected {
+		t.Errorf("expected '%s'\nbut got '%s'", expected, buf)
+	}
+}
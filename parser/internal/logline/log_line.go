@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 	"unicode"
 )
@@ -14,26 +15,49 @@ const (
 )
 
 func ParseLogLine(input string) (map[string]interface{}, error) {
-	p := nonPegLogLineParser{Buffer: input}
-	p.Init()
-	if err := p.Parse(); err != nil {
-		return nil, err
+	fields, _, err := Parse(input)
+	return fields, err
+}
+
+// Parse is ParseLogLine, also returning the order of the keys of the line's documents
+func Parse(input string) (map[string]interface{}, KeyOrder, error) {
+	var fields map[string]interface{}
+	var order KeyOrder
+	if isJSONLogLine(input) {
+		var err error
+		if fields, order, err = parseJSONLogLine(input); err != nil {
+			return nil, nil, jsonParseError(input, err)
+		}
+	} else {
+		p := nonPegLogLineParser{Buffer: input}
+		p.Init()
+		if err := p.Parse(); err != nil {
+			return nil, nil, p.asParseError(err)
+		}
+		fields, order = p.Fields, p.KeyOrder
 	}
-	return p.Fields, nil
 
+	// also calculate the query_shape if we can
+	addQueryShape(fields, order)
+	addWriteOpFields(fields)
+	return fields, order, nil
 }
 
 type nonPegLogLineParser struct {
-	Buffer string
-	Fields map[string]interface{}
+	Buffer   string
+	Fields   map[string]interface{}
+	KeyOrder KeyOrder
 
 	runes    []rune
 	position int
+	// path is the path of the value being parsed, for KeyOrder
+	path []string
 }
 
 func (p *nonPegLogLineParser) Init() {
 	p.runes = append([]rune(p.Buffer), endRune)
 	p.Fields = make(map[string]interface{})
+	p.KeyOrder = make(KeyOrder)
 }
 
 func (p *nonPegLogLineParser) Parse() error {
@@ -41,9 +65,15 @@ func (p *nonPegLogLineParser) Parse() error {
 	if err = p.parseTimestamp(); err != nil {
 		return err
 	}
+	p.eatWhitespace()
 	if p.lookahead(0) == '[' {
-		// we assume version < 3.0
-		panic("< 3.0 support not implemented")
+		// we assume version < 3.0, which has no severity or component
+		if err = p.parseContext(); err != nil {
+			return err
+		}
+		if err = p.parseMessage(); err != nil {
+			return err
+		}
 	} else {
 		// we assume version > 3.0
 		if err = p.parseSeverity(); err != nil {
@@ -60,15 +90,6 @@ func (p *nonPegLogLineParser) Parse() error {
 		}
 	}
 
-	/*
-		if q, ok := p.Fields["query"]; ok {
-			if _, ok = p.Fields["query_shape"]; !ok {
-				// also calculate the query_shape if we can
-				p.Fields["query_shape"] = getQueryShape(q)
-			}
-		}
-	*/
-
 	return nil
 }
 
@@ -89,13 +110,15 @@ func (p *nonPegLogLineParser) parseTimestamp() error {
 		// we assume it's ctime or ctime-no-ms
 		var dayOfWeek, month, day, time string
 
+		start := p.position
 		if dayOfWeek, err = validDayOfWeek(p.readUntil(unicode.Space)); err != nil {
-			return err
+			return p.failAt(start, "day of week", err.Error())
 		}
 
 		p.eatWhitespace()
+		start = p.position
 		if month, err = validMonth(p.readUntil(unicode.Space)); err != nil {
-			return err
+			return p.failAt(start, "month", err.Error())
 		}
 
 		p.eatWhitespace()
@@ -119,9 +142,9 @@ func (p *nonPegLogLineParser) parseSeverity() error {
 	var err error
 	p.eatWhitespace()
 	if p.Fields["severity"], err = severityToString(p.advance()); err != nil {
-		return err
+		return p.failAt(p.position-1, "severity", err.Error())
 	}
-	if err = p.expectRange(unicode.Space, "expected space after severity"); err != nil {
+	if err = p.expectRange(unicode.Space, "space", "expected space after severity"); err != nil {
 		return err
 	}
 	return nil
@@ -192,9 +215,13 @@ func (p *nonPegLogLineParser) parseMessage() error {
 	} else {
 		p.position = savedPosition
 
-		if p.Fields["message"], err = p.readUntilRune(endRune); err != nil {
+		var message string
+		if message, err = p.readUntilRune(endRune); err != nil {
 			return err
 		}
+		p.Fields["message"] = message
+		parseConnectionMessage(p.Fields, message)
+		parseReplMessage(p.Fields, message)
 	}
 
 	return nil
@@ -233,6 +260,18 @@ func (p *nonPegLogLineParser) parseFieldAndValue() (bool, error) {
 
 	p.eatWhitespace()
 
+	if flag := p.readBareFlag(); flag != "" {
+		p.Fields[flag] = true
+		return false, nil
+	}
+
+	// < 3.0 logs the time each lock was held after a bare marker, as in
+	// "locks(micros) r:86 w:120"
+	if p.matchAhead(p.position, "locks(micros)") {
+		p.position += len("locks(micros)")
+		return false, p.parseLegacyLocks()
+	}
+
 	savedPosition := p.position
 	if fieldName, err = p.readUntilRune(':'); err != nil {
 		p.position = savedPosition
@@ -241,6 +280,8 @@ func (p *nonPegLogLineParser) parseFieldAndValue() (bool, error) {
 	p.position++ // skip the ':'
 	p.eatWhitespace()
 
+	p.path = append(p.path[:0], fieldName)
+
 	// some known fields have a more complicated structure
 	if fieldName == "planSummary" {
 		if fieldValue, err = p.parsePlanSummary(); err != nil {
@@ -279,22 +320,44 @@ func (p *nonPegLogLineParser) parseFieldAndValue() (bool, error) {
 				return false, err
 			}
 		default:
-			return false, errors.New(fmt.Sprintf("unexpected start character for value of field '%s'", fieldName))
+			return false, p.fail("value", fmt.Sprintf("unexpected start character for value of field '%s'", fieldName))
 		}
 	}
 
+	if locks, ok := fieldValue.(map[string]interface{}); ok && fieldName == "locks" {
+		fieldValue = normalizeLocks(locks)
+	}
+
 	p.Fields[fieldName] = fieldValue
 	//fmt.Println("done parsing field ", fieldName)
 	return false, nil
 }
 
+// parseLegacyLocks parses the mode:micros pairs following "locks(micros)", stopping
+// at the first field that isn't a lock mode
+func (p *nonPegLogLineParser) parseLegacyLocks() error {
+	for {
+		p.eatWhitespace()
+		mode := string(p.lookahead(0))
+		if _, ok := legacyLockResources[mode]; !ok || p.lookahead(1) != ':' {
+			return nil
+		}
+		p.position += 2
+		micros, err := p.readNumber()
+		if err != nil {
+			return err
+		}
+		addLegacyLock(p.Fields, mode, micros)
+	}
+}
+
 func (p *nonPegLogLineParser) parsePlanSummary() (interface{}, error) {
 	var rv []interface{}
 
 	p.eatWhitespace()
 
 	for {
-		elem, err := p.parsePlanSummaryElement()
+		elem, err := p.parsePlanSummaryElement(len(rv))
 		if err != nil {
 			return nil, err
 		}
@@ -313,7 +376,9 @@ func (p *nonPegLogLineParser) parsePlanSummary() (interface{}, error) {
 	return rv, nil
 }
 
-func (p *nonPegLogLineParser) parsePlanSummaryElement() (interface{}, error) {
+// parsePlanSummaryElement parses a stage such as COLLSCAN or IXSCAN { a: 1 }, the
+// i'th of the plan summary
+func (p *nonPegLogLineParser) parsePlanSummaryElement(i int) (interface{}, error) {
 	rv := make(map[string]interface{})
 
 	p.eatWhitespace()
@@ -331,7 +396,11 @@ func (p *nonPegLogLineParser) parsePlanSummaryElement() (interface{}, error) {
 	p.eatWhitespace()
 	c := p.lookahead(0)
 	if c == '{' {
-		if rv[stage], err = p.parseJSONMap(); err != nil {
+		// the key order of an index key pattern is kept as planSummary.<i>.<stage>
+		p.path = append(p.path, strconv.Itoa(i), stage)
+		rv[stage], err = p.parseJSONMap()
+		p.path = p.path[:len(p.path)-2]
+		if err != nil {
 			return nil, nil
 		}
 	} else {
@@ -350,7 +419,7 @@ func (p *nonPegLogLineParser) readNumber() (float64, error) {
 	}
 
 	if p.runes[endPosition] == endRune {
-		return 0, errors.New("found end of line before expected unicode range")
+		return 0, p.failAt(endPosition, "number", "found end of line before expected unicode range")
 	}
 
 	p.position = endPosition
@@ -372,7 +441,7 @@ func (p *nonPegLogLineParser) readDuration() (float64, error) {
 	}
 
 	if p.runes[endPosition] != 'm' || p.runes[endPosition+1] != 's' {
-		return 0, errors.New("invalid duration specifier")
+		return 0, p.failAt(endPosition, "duration", "invalid duration specifier")
 	}
 
 	rv, err := strconv.ParseFloat(string(p.runes[startPosition:endPosition]), 64)
@@ -389,6 +458,7 @@ func (p *nonPegLogLineParser) parseJSONMap() (interface{}, error) {
 	p.position++
 
 	rv := make(map[string]interface{})
+	var keys []string
 
 	for {
 		var key string
@@ -417,10 +487,13 @@ func (p *nonPegLogLineParser) parseJSONMap() (interface{}, error) {
 			}
 			p.eatWhitespace()
 
+			p.path = append(p.path, key)
 			if value, err = p.parseJSONValue(); err != nil {
 				return nil, err
 			}
+			p.path = p.path[:len(p.path)-1]
 			rv[key] = value
+			keys = append(keys, key)
 		}
 
 		p.eatWhitespace()
@@ -432,11 +505,14 @@ func (p *nonPegLogLineParser) parseJSONMap() (interface{}, error) {
 		} else if commaOrRbrace == ',' {
 			p.position++
 		} else {
-			return nil, errors.New("expected '}' or ',' in json")
+			return nil, p.fail("'}' or ','", "expected '}' or ',' in json")
 		}
 
 	}
 
+	if len(keys) != 0 {
+		p.KeyOrder[strings.Join(p.path, ".")] = keys
+	}
 	return rv, nil
 }
 
@@ -456,9 +532,11 @@ func (p *nonPegLogLineParser) parseJSONArray() (interface{}, error) {
 		var value interface{}
 		var err error
 
+		p.path = append(p.path, strconv.Itoa(len(rv)))
 		if value, err = p.parseJSONValue(); err != nil {
 			return nil, err
 		}
+		p.path = p.path[:len(p.path)-1]
 
 		rv = append(rv, value)
 
@@ -471,7 +549,7 @@ func (p *nonPegLogLineParser) parseJSONArray() (interface{}, error) {
 		} else if commaOrRbrace == ',' {
 			p.position++
 		} else {
-			return nil, errors.New("expected ']' or ',' in json")
+			return nil, p.fail("']' or ','", "expected ']' or ',' in json")
 		}
 		p.eatWhitespace()
 	}
@@ -524,7 +602,7 @@ func (p *nonPegLogLineParser) parseJSONValue() (interface{}, error) {
 
 				endPosition++
 				if p.runes[endPosition] == endRune {
-					return nil, errors.New("unexpected end of line reading json value")
+					return nil, p.failAt(endPosition, "'\"'", "unexpected end of line reading json value")
 				}
 			}
 			value = string(p.runes[p.position:endPosition])
@@ -547,7 +625,7 @@ func (p *nonPegLogLineParser) parseJSONValue() (interface{}, error) {
 				return nil, err
 			}
 			if value != "Date" {
-				return nil, errors.New(fmt.Sprintf("unexpected constructor: %s", value))
+				return nil, p.fail("Date", fmt.Sprintf("unexpected constructor: %s", value))
 			}
 			// we expect "new Date(123456789)"
 			if err = p.expect('('); err != nil {
@@ -562,7 +640,7 @@ func (p *nonPegLogLineParser) parseJSONValue() (interface{}, error) {
 			}
 
 			if math.Floor(dateNum) != dateNum {
-				return nil, errors.New(fmt.Sprintf("expected int in `new Date()`"))
+				return nil, p.fail("integer", "expected int in `new Date()`")
 			}
 			unixSec := int64(dateNum) / 1000
 			unixNS := int64(dateNum) % 1000 * 1000000
@@ -591,7 +669,7 @@ func (p *nonPegLogLineParser) parseJSONValue() (interface{}, error) {
 			}
 			quote := p.lookahead(0) // keep ahold of the quote so we can match it
 			if p.lookahead(0) != '\'' && p.lookahead(0) != '"' {
-				return nil, errors.New("expected ' or \" in ObjectId")
+				return nil, p.fail("' or \"", "expected ' or \" in ObjectId")
 			}
 			p.position++
 
@@ -606,13 +684,12 @@ func (p *nonPegLogLineParser) parseJSONValue() (interface{}, error) {
 			if err = p.expect(')'); err != nil {
 				return nil, err
 			}
-			value = hex
-			// XXX(toshok) more here
+			value = ObjectID(hex)
 		} else {
-			return nil, errors.New(fmt.Sprintf("unexpected start of JSON value: %s", value))
+			return nil, p.fail("JSON value", fmt.Sprintf("unexpected start of JSON value: %s", value))
 		}
 	default:
-		return nil, errors.New(fmt.Sprintf("unexpected start character for JSON value of field: %s", string([]rune{firstCharInVal})))
+		return nil, p.fail("JSON value", fmt.Sprintf("unexpected start character for JSON value of field: %s", string([]rune{firstCharInVal})))
 	}
 
 	return value, nil
@@ -661,7 +738,7 @@ func (p *nonPegLogLineParser) readUntil(untilRangeTable *unicode.RangeTable) (st
 	}
 
 	if p.runes[endPosition] == endRune {
-		return "", errors.New("found end of line before expected unicode range")
+		return "", p.failAt(endPosition, "space", "found end of line before expected unicode range")
 	}
 
 	p.position = endPosition
@@ -677,7 +754,7 @@ func (p *nonPegLogLineParser) readUntilRune(untilRune rune) (string, error) {
 	}
 
 	if p.runes[endPosition] == endRune && untilRune != endRune {
-		return "", errors.New(fmt.Sprintf("found end of line before expected rune '%s'", string([]rune{untilRune})))
+		return "", p.failAt(endPosition, "'"+string([]rune{untilRune})+"'", fmt.Sprintf("found end of line before expected rune '%s'", string([]rune{untilRune})))
 	}
 
 	p.position = endPosition
@@ -697,7 +774,7 @@ func (p *nonPegLogLineParser) readWhile(checks []interface{}) (string, error) {
 	}
 
 	if p.runes[endPosition] == endRune {
-		return "", errors.New("unexpected end of line")
+		return "", p.failAt(endPosition, "", "unexpected end of line")
 	}
 
 	p.position = endPosition
@@ -728,14 +805,14 @@ func (p *nonPegLogLineParser) advance() rune {
 func (p *nonPegLogLineParser) expect(past rune) error {
 	r := p.advance()
 	if r != past {
-		return errors.New(fmt.Sprintf("expected '%s', but got '%s'", string([]rune{past}), string([]rune{r})))
+		return p.failAt(p.position-1, "'"+string([]rune{past})+"'", fmt.Sprintf("expected '%s', but got '%s'", string([]rune{past}), string([]rune{r})))
 	}
 	return nil
 }
 
-func (p *nonPegLogLineParser) expectRange(rt *unicode.RangeTable, errStr string) error {
+func (p *nonPegLogLineParser) expectRange(rt *unicode.RangeTable, expected, errStr string) error {
 	if !unicode.Is(rt, p.advance()) {
-		return errors.New(errStr)
+		return p.failAt(p.position-1, expected, errStr)
 	}
 	return nil
 }
@@ -786,18 +863,37 @@ func doCheck(r rune, c interface{}) bool {
 	}
 	return false
 }
+
+var (
+	daysOfWeek = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	months     = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+)
+
 func validDayOfWeek(dayOfWeek string, err error) (string, error) {
-	if len(dayOfWeek) != 3 {
-		return "", errors.New("invalid day of week")
+	if err != nil {
+		return "", err
+	}
+	if !contains(daysOfWeek, dayOfWeek) {
+		return "", errors.New(fmt.Sprintf("invalid day of week '%s'", dayOfWeek))
 	}
-	// XXX(toshok) validate against a list
 	return dayOfWeek, nil
 }
 
 func validMonth(month string, err error) (string, error) {
-	if len(month) != 3 {
-		return "", errors.New("invalid month")
+	if err != nil {
+		return "", err
+	}
+	if !contains(months, month) {
+		return "", errors.New(fmt.Sprintf("invalid month '%s'", month))
 	}
-	// XXX(toshok) validate against a list
 	return month, nil
 }
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
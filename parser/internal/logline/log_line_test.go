@@ -0,0 +1,88 @@
+package logline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+func TestLogLineParser(t *testing.T) {
+	cases := []struct{ input, expected string }{
+		// < 3.0
+		{
+			`Mon Feb 23 03:20:19.670 [TTLMonitor] query local.system.indexes query: { expireAfterSeconds: { $exists: true } } ntoreturn:0 ntoskip:0 nscanned:0 keyUpdates:0 locks(micros) r:86 nreturned:0 reslen:20 0ms`,
+			`{"context":"TTLMonitor","duration":0,"keyUpdates":0,"locks":{"Database":{"r":{"locked_micros":86}}},"namespace":"local.system.indexes","nreturned":0,"nscanned":0,"ntoreturn":0,"ntoskip":0,"operation":"query","query":{"expireAfterSeconds":{"$exists":true}},"query_shape":"{\"expireAfterSeconds\":{\"$exists\":1}}","query_shape_hash":"D00816D8","reslen":20,"timestamp":"Mon Feb 23 03:20:19.670"}`,
+		},
+		{
+			`2014-06-02T11:31:45.116-0400 [conn5] query test.foo query: { a: 1 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:10 keyUpdates:0 numYields:0 locks(micros) r:120 nreturned:1 reslen:40 0ms`,
+			`{"context":"conn5","duration":0,"keyUpdates":0,"locks":{"Database":{"r":{"locked_micros":120}}},"namespace":"test.foo","nreturned":1,"nscanned":0,"nscannedObjects":10,"ntoreturn":0,"ntoskip":0,"numYields":0,"operation":"query","planSummary":[{"COLLSCAN":true}],"query":{"a":1},"query_shape":"{\"a\":1}","query_shape_hash":"DB26B9C3","reslen":40,"timestamp":"2014-06-02T11:31:45.116-0400"}`,
+		},
+		{
+			`Wed Jun  4 12:00:01.123 [initandlisten] waiting for connections on port 27017`,
+			`{"context":"initandlisten","message":"waiting for connections on port 27017","timestamp":"Wed Jun 4 12:00:01.123"}`,
+		},
+		{
+			`Wed Jun  4 12:00:01.123 [initandlisten] connection accepted from 10.0.0.5:53422 #1234 (56 connections now open)`,
+			`{"client_ip":"10.0.0.5","client_port":53422,"connection_event":"accepted","connection_id":1234,"connections_open":56,"context":"initandlisten","message":"connection accepted from 10.0.0.5:53422 #1234 (56 connections now open)","timestamp":"Wed Jun 4 12:00:01.123"}`,
+		},
+		// >= 3.0
+		{
+			`2015-03-04T11:31:45.116-0800 I NETWORK  [conn1234] end connection 10.0.0.5:53422 (1 connection now open)`,
+			`{"client_ip":"10.0.0.5","client_port":53422,"component":"NETWORK","connection_event":"ended","connection_id":1234,"connections_open":1,"context":"conn1234","message":"end connection 10.0.0.5:53422 (1 connection now open)","severity":"informational","timestamp":"2015-03-04T11:31:45.116-0800"}`,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I COMMAND  [conn2] command test.$cmd command: count { count: "foo", query: { a: 1 } } planSummary: COUNT_SCAN { a: 1 } keyUpdates:0 writeConflicts:0 numYields:0 reslen:44 locks:{ Global: { acquireCount: { r: 2 } }, Database: { acquireCount: { r: 1 } } } 2ms`,
+			`{"command":{"count":"foo","query":{"a":1}},"command_type":"count","component":"COMMAND","context":"conn2","duration":2,"keyUpdates":0,"locks":{"Database":{"r":{"acquire_count":1}},"Global":{"r":{"acquire_count":2}}},"namespace":"test.$cmd","numYields":0,"operation":"command","planSummary":[{"COUNT_SCAN":{"a":1}}],"query_shape":"{\"a\":1}","query_shape_hash":"DB26B9C3","reslen":44,"severity":"informational","timestamp":"2015-03-04T11:31:45.116-0800","writeConflicts":0}`,
+		},
+		// >= 4.4
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.foo","command":{"find":"foo","filter":{"a":{"$gt":5}},"$db":"test"},"planSummary":"IXSCAN { a: 1 }","keysExamined":10,"docsExamined":10,"nreturned":10,"reslen":1234,"durationMillis":105}}`,
+			`{"command":{"$db":"test","filter":{"a":{"$gt":5}},"find":"foo"},"command_type":"find","component":"COMMAND","context":"conn1","docsExamined":10,"duration":105,"id":51803,"keysExamined":10,"namespace":"test.foo","nreturned":10,"operation":"command","planSummary":[{"IXSCAN":{"a":1}}],"query_shape":"{\"a\":{\"$gt\":1}}","query_shape_hash":"C1F57CBE","reslen":1234,"severity":"informational","timestamp":"2020-05-20T19:18:40.604+00:00"}`,
+		},
+		// only commands get a command_type
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"WRITE","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"update","ns":"test.foo","command":{"q":{"a":1},"u":{"$set":{"b":1}},"multi":false,"upsert":false},"planSummary":"COLLSCAN","keysExamined":0,"docsExamined":10,"nMatched":1,"nModified":1,"numYields":0,"durationMillis":105}}`,
+			`{"command":{"multi":false,"q":{"a":1},"u":{"$set":{"b":1}},"upsert":false},"component":"WRITE","context":"conn1","docsExamined":10,"duration":105,"id":51803,"keysExamined":0,"nMatched":1,"nModified":1,"namespace":"test.foo","numYields":0,"operation":"update","planSummary":[{"COLLSCAN":true}],"query_shape":"{\"a\":1}","query_shape_hash":"DB26B9C3","severity":"informational","timestamp":"2020-05-20T19:18:40.604+00:00","update_operators":["$set"],"update_type":"operator","upsert":false}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"WRITE","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"remove","ns":"test.foo","command":{"q":{"a":1},"limit":0},"planSummary":"COLLSCAN","keysExamined":0,"docsExamined":10,"ndeleted":1,"numYields":0,"durationMillis":105}}`,
+			`{"command":{"limit":0,"q":{"a":1}},"component":"WRITE","context":"conn1","docsExamined":10,"duration":105,"id":51803,"keysExamined":0,"namespace":"test.foo","ndeleted":1,"numYields":0,"operation":"remove","planSummary":[{"COLLSCAN":true}],"query_shape":"{\"a\":1}","query_shape_hash":"DB26B9C3","severity":"informational","timestamp":"2020-05-20T19:18:40.604+00:00"}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"D2","c":"NETWORK","id":22943,"ctx":"listener","msg":"Connection accepted","attr":{"remote":"127.0.0.1:53422","connectionId":1}}`,
+			`{"attr":{"connectionId":1,"remote":"127.0.0.1:53422"},"client_ip":"127.0.0.1","client_port":53422,"component":"NETWORK","connection_event":"accepted","connection_id":1,"context":"listener","id":22943,"message":"Connection accepted","severity":"debug","timestamp":"2020-05-20T19:18:40.604+00:00"}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:41.604+00:00"},"s":"I","c":"NETWORK","id":22944,"ctx":"conn1","msg":"Connection ended","attr":{"remote":"[::1]:53422","connectionId":1,"connectionCount":0}}`,
+			`{"attr":{"connectionCount":0,"connectionId":1,"remote":"[::1]:53422"},"client_ip":"::1","client_port":53422,"component":"NETWORK","connection_event":"ended","connection_id":1,"connections_open":0,"context":"conn1","id":22944,"message":"Connection ended","severity":"informational","timestamp":"2020-05-20T19:18:41.604+00:00"}`,
+		},
+	}
+	for i, testcase := range cases {
+		doc, err := logline.ParseLogLine(testcase.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		buf, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("case %d: error marshaling: %v", i, err)
+		}
+		result := string(buf)
+		if result != testcase.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, testcase.expected, result)
+		}
+	}
+}
+
+func TestLogLineParserErrors(t *testing.T) {
+	cases := []string{
+		`Mon Foo 23 03:20:19.670 [TTLMonitor] query local.system.indexes query: {} 0ms`,
+		`Xyz Feb 23 03:20:19.670 [TTLMonitor] query local.system.indexes query: {} 0ms`,
+		`Monday Feb 23 03:20:19.670 [TTLMonitor] query local.system.indexes query: {} 0ms`,
+	}
+	for i, testcase := range cases {
+		if _, err := logline.ParseLogLine(testcase); err == nil {
+			t.Errorf("case %d: expected an error parsing '%s'", i, testcase)
+		}
+	}
+}
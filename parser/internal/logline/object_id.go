@@ -0,0 +1,6 @@
+package logline
+
+// ObjectID is a value logged as ObjectId('...'), kept as its hex string.  It's a type
+// of its own so that the output formats with an ObjectId type can tell it apart from
+// a string that happens to look like one.  It marshals to JSON as the hex string.
+type ObjectID string
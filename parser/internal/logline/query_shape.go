@@ -0,0 +1,178 @@
+package logline
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// queryShapePlaceholder replaces every literal value in a query shape
+const queryShapePlaceholder = 1
+
+// operators whose operands are lists of queries, rather than literal values
+var queryListOperators = map[string]bool{"$and": true, "$or": true, "$nor": true}
+
+// addQueryShape adds the query_shape, sort_shape and query_shape_hash fields to an
+// operation that has a filter or sort document.  The keys of a filter are sorted,
+// since their order doesn't matter, but the sort keeps its keys in the order they
+// were logged.
+func addQueryShape(fields map[string]interface{}, order KeyOrder) {
+	if _, ok := fields["query_shape"]; ok {
+		return
+	}
+	filter, sortDoc, sortPath := queryShapeDocs(fields)
+	if filter == nil && sortDoc == nil {
+		return
+	}
+
+	var queryShape, sortShape string
+	if filter != nil {
+		queryShape = shapeString(getQueryShape(filter))
+		fields["query_shape"] = queryShape
+	}
+	if sortDoc != nil {
+		sortShape = orderedShapeString(sortDoc, order.Keys(sortPath, sortDoc))
+		fields["sort_shape"] = sortShape
+	}
+	fields["query_shape_hash"] = queryShapeHash(queryShape, sortShape)
+}
+
+// queryShapeDocs finds the filter and sort documents of an operation, and the path
+// of the sort.  Queries put them in the query field (wrapped in
+// { query: ..., orderby: ... } when there's a sort), updates and removes put the
+// filter there, and commands have them in the command document.
+func queryShapeDocs(fields map[string]interface{}) (filter, sortDoc map[string]interface{}, sortPath string) {
+	if query, ok := fields["query"].(map[string]interface{}); ok {
+		return unwrapQuery(query)
+	}
+	if command, ok := fields["command"].(map[string]interface{}); ok {
+		for _, key := range []string{"filter", "query", "q"} {
+			if filter, ok = command[key].(map[string]interface{}); ok {
+				break
+			}
+		}
+		sortDoc, _ = command["sort"].(map[string]interface{})
+	}
+	return filter, sortDoc, "command.sort"
+}
+
+func unwrapQuery(query map[string]interface{}) (filter, sortDoc map[string]interface{}, sortPath string) {
+	if filter, ok := query["$query"].(map[string]interface{}); ok {
+		sortDoc, _ = query["$orderby"].(map[string]interface{})
+		return filter, sortDoc, "query.$orderby"
+	}
+	if sortDoc, ok := query["orderby"].(map[string]interface{}); ok {
+		filter, _ = query["query"].(map[string]interface{})
+		return filter, sortDoc, "query.orderby"
+	}
+	return query, nil, ""
+}
+
+// getQueryShape replaces the literal values in a query with a placeholder, leaving
+// field names and operators in place.
+func getQueryShape(query interface{}) interface{} {
+	doc, ok := query.(map[string]interface{})
+	if !ok {
+		return queryShapePlaceholder
+	}
+
+	shape := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		switch {
+		case queryListOperators[key]:
+			shape[key] = getQueryShapeList(value)
+		case strings.HasPrefix(key, "$"):
+			// top level operators such as $where or $text
+			shape[key] = queryShapePlaceholder
+		default:
+			shape[key] = getValueShape(value)
+		}
+	}
+	return shape
+}
+
+// getValueShape shapes the value a field is matched against.  That's either a
+// document of operators such as { $gt: 5 }, or a literal.
+func getValueShape(value interface{}) interface{} {
+	doc, ok := value.(map[string]interface{})
+	if !ok || !isOperatorDoc(doc) {
+		return queryShapePlaceholder
+	}
+
+	shape := make(map[string]interface{}, len(doc))
+	for key, operand := range doc {
+		switch key {
+		case "$elemMatch":
+			shape[key] = getQueryShape(operand)
+		case "$not":
+			shape[key] = getValueShape(operand)
+		default:
+			shape[key] = queryShapePlaceholder
+		}
+	}
+	return shape
+}
+
+// getQueryShapeList shapes the clauses of an $and, $or or $nor.  The clauses are
+// sorted so that the same clauses in a different order have the same shape.
+func getQueryShapeList(value interface{}) interface{} {
+	clauses, ok := value.([]interface{})
+	if !ok {
+		return queryShapePlaceholder
+	}
+
+	shapes := make([]interface{}, len(clauses))
+	for i, clause := range clauses {
+		shapes[i] = getQueryShape(clause)
+	}
+	sort.Slice(shapes, func(i, j int) bool {
+		return shapeString(shapes[i]) < shapeString(shapes[j])
+	})
+	return shapes
+}
+
+// isOperatorDoc returns true for documents such as { $gt: 5 }
+func isOperatorDoc(doc map[string]interface{}) bool {
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return len(doc) != 0
+}
+
+// shapeString serializes a shape.  encoding/json sorts map keys, which normalizes
+// the key order for us.
+func shapeString(shape interface{}) string {
+	buf, err := json.Marshal(shape)
+	if err != nil {
+		return fmt.Sprintf("%v", shape)
+	}
+	return string(buf)
+}
+
+// orderedShapeString is shapeString for a document whose keys must stay in order
+func orderedShapeString(doc map[string]interface{}, keys []string) string {
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(shapeString(key))
+		buf.WriteByte(':')
+		buf.WriteString(shapeString(doc[key]))
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+func queryShapeHash(queryShape, sortShape string) string {
+	h := fnv.New32a()
+	h.Write([]byte(queryShape))
+	h.Write([]byte{0})
+	h.Write([]byte(sortShape))
+	return fmt.Sprintf("%08X", h.Sum32())
+}
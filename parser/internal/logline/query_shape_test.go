@@ -0,0 +1,69 @@
+package logline_test
+
+import (
+	"testing"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+func TestQueryShape(t *testing.T) {
+	cases := []struct{ input, shape, sortShape string }{
+		{
+			`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 5, b: { $in: [ 1, 2 ] }, e: { x: 1 } } 1ms`,
+			`{"a":1,"b":{"$in":1},"e":1}`, ``,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { query: { $or: [ { d: { $gt: 3 } }, { c: "x" } ] }, orderby: { b: -1 } } 1ms`,
+			`{"$or":[{"c":1},{"d":{"$gt":1}}]}`, `{"b":-1}`,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { $query: { tags: { $elemMatch: { k: "a", v: { $not: { $exists: true } } } } }, $orderby: { _id: 1 } } 1ms`,
+			`{"tags":{"$elemMatch":{"k":1,"v":{"$not":{"$exists":1}}}}}`, `{"_id":1}`,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: ObjectId('54e792daf1845f045f4c000e') } update: { $set: { a: 1 } } 1ms`,
+			`{"_id":1}`, ``,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I COMMAND  [conn2] command test.$cmd command: find { find: "foo", filter: { a: { $gte: 1, $lt: 5 } }, sort: { a: 1 } } 1ms`,
+			`{"a":{"$gte":1,"$lt":1}}`, `{"a":1}`,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I COMMAND  [conn2] command test.$cmd command: find { find: "foo", filter: { a: { $gte: 1, $lt: 5 } }, sort: { b: -1, a: 1 } } 1ms`,
+			`{"a":{"$gte":1,"$lt":1}}`, `{"b":-1,"a":1}`,
+		},
+		{
+			`2015-03-04T11:31:45.116-0800 I COMMAND  [conn2] command test.$cmd command: find { find: "foo", filter: { a: { $gte: 1, $lt: 5 } }, sort: { a: 1, b: -1 } } 1ms`,
+			`{"a":{"$gte":1,"$lt":1}}`, `{"a":1,"b":-1}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.foo","command":{"find":"foo","filter":{"a":{"$gt":5}},"sort":{"z":1,"m":-1},"$db":"test"},"durationMillis":105}}`,
+			`{"a":{"$gt":1}}`, `{"z":1,"m":-1}`,
+		},
+	}
+	hashes := make(map[string]string)
+	for i, testcase := range cases {
+		doc, err := logline.ParseLogLine(testcase.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		if shape, _ := doc["query_shape"].(string); shape != testcase.shape {
+			t.Errorf("case %d: expected query shape '%s'\nbut got '%s'", i, testcase.shape, shape)
+		}
+		if sortShape, _ := doc["sort_shape"].(string); sortShape != testcase.sortShape {
+			t.Errorf("case %d: expected sort shape '%s'\nbut got '%s'", i, testcase.sortShape, sortShape)
+		}
+		hash, _ := doc["query_shape_hash"].(string)
+		if other, ok := hashes[hash]; ok {
+			t.Errorf("case %d: same hash as '%s'", i, other)
+		}
+		hashes[hash] = testcase.shape
+	}
+
+	// the same shape with different literals and key order has the same hash
+	a, _ := logline.ParseLogLine(`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1, b: "x" } 1ms`)
+	b, _ := logline.ParseLogLine(`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { b: "y", a: 2 } 1ms`)
+	if a["query_shape_hash"] != b["query_shape_hash"] {
+		t.Errorf("expected equal hashes, got %v and %v", a["query_shape_hash"], b["query_shape_hash"])
+	}
+}
@@ -0,0 +1,94 @@
+package logline
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// replRecognizer turns a REPL message matching re into a repl_event.  The named
+// groups old, new, member and term become fields.
+type replRecognizer struct {
+	event string
+	re    *regexp.Regexp
+}
+
+// the replica set messages of the text formats, e.g.
+//
+//	transition to PRIMARY from SECONDARY
+//	replSet member db2.example.com:27017 is now in state SECONDARY
+//	election succeeded, assuming primary role in term 5
+var replRecognizers = []replRecognizer{
+	{"state_change", regexp.MustCompile(`^transition to (?P<new>[A-Z0-9]+)(?: from (?P<old>[A-Z0-9]+))?`)},
+	// < 3.0 announces its own state
+	{"state_change", regexp.MustCompile(`^replSet (?P<new>PRIMARY|SECONDARY|RECOVERING|STARTUP2|ARBITER|ROLLBACK|REMOVED|FATAL)$`)},
+	{"member_state", regexp.MustCompile(`^(?:replSet member|Member) (?P<member>\S+) is now in state (?P<new>[A-Z0-9]+)`)},
+	{"election_start", regexp.MustCompile(`^(?:Starting an election|conducting a dry run election|dry election run succeeded, running for election|replSet info electSelf)(?:.* in term (?P<term>\d+))?`)},
+	{"election_won", regexp.MustCompile(`^(?:replSet )?election succeeded, assuming primary role(?: in term (?P<term>\d+))?`)},
+	{"rollback", regexp.MustCompile(`^(?:replSet )?(?:beginning rollback|rollback 0$|Starting rollback)`)},
+}
+
+// parseReplMessage adds the repl_event field, and old_state, new_state, member and
+// term where the message has them, for a replica set state change, election or
+// rollback
+func parseReplMessage(fields map[string]interface{}, message string) {
+	for _, r := range replRecognizers {
+		m := r.re.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		values := make(map[string]string)
+		for i, name := range r.re.SubexpNames() {
+			if name != "" {
+				values[name] = m[i]
+			}
+		}
+		setReplFields(fields, r.event, values["old"], values["new"], values["member"], values["term"])
+		return
+	}
+}
+
+// parseJSONReplMessage is parseReplMessage for the messages of >= 4.4, which carry
+// the states, member and term in attr
+func parseJSONReplMessage(fields map[string]interface{}, msg string, attr map[string]interface{}) {
+	var event string
+	switch {
+	case msg == "Replica set state transition":
+		event = "state_change"
+	case msg == "Member is in new state":
+		event = "member_state"
+	case strings.HasPrefix(msg, "Starting an election"):
+		event = "election_start"
+	case strings.HasPrefix(msg, "Election succeeded"):
+		event = "election_won"
+	case strings.HasPrefix(msg, "Starting rollback"):
+		event = "rollback"
+	default:
+		return
+	}
+	oldState, _ := attr["oldState"].(string)
+	newState, _ := attr["newState"].(string)
+	member, _ := attr["hostAndPort"].(string)
+	var term string
+	if n, ok := attr["term"].(float64); ok {
+		term = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	setReplFields(fields, event, oldState, newState, member, term)
+}
+
+// setReplFields sets the replica set fields, leaving out those that are empty
+func setReplFields(fields map[string]interface{}, event, oldState, newState, member, term string) {
+	fields["repl_event"] = event
+	if oldState != "" {
+		fields["old_state"] = oldState
+	}
+	if newState != "" {
+		fields["new_state"] = newState
+	}
+	if member != "" {
+		fields["member"] = member
+	}
+	if n, err := strconv.ParseFloat(term, 64); err == nil {
+		fields["term"] = n
+	}
+}
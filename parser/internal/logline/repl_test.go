@@ -0,0 +1,71 @@
+package logline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+// replFields returns the replica set fields of a parsed line as JSON
+func replFields(t *testing.T, line string) string {
+	fields, err := logline.ParseLogLine(line)
+	if err != nil {
+		t.Fatalf("error parsing '%s': %v", line, err)
+	}
+	repl := make(map[string]interface{})
+	for _, name := range []string{"repl_event", "old_state", "new_state", "member", "term"} {
+		if v, ok := fields[name]; ok {
+			repl[name] = v
+		}
+	}
+	buf, _ := json.Marshal(repl)
+	return string(buf)
+}
+
+func TestReplMessages(t *testing.T) {
+	cases := []struct{ input, expected string }{
+		{`transition to PRIMARY`, `{"new_state":"PRIMARY","repl_event":"state_change"}`},
+		{`transition to SECONDARY from PRIMARY`, `{"new_state":"SECONDARY","old_state":"PRIMARY","repl_event":"state_change"}`},
+		{`replSet SECONDARY`, `{"new_state":"SECONDARY","repl_event":"state_change"}`},
+		{`replSet member db2.example.com:27017 is now in state SECONDARY`, `{"member":"db2.example.com:27017","new_state":"SECONDARY","repl_event":"member_state"}`},
+		{`Member db3.example.com:27017 is now in state ARBITER`, `{"member":"db3.example.com:27017","new_state":"ARBITER","repl_event":"member_state"}`},
+		{`Starting an election, since we've seen no PRIMARY in the past 10000ms`, `{"repl_event":"election_start"}`},
+		{`dry election run succeeded, running for election in term 6`, `{"repl_event":"election_start","term":6}`},
+		{`replSet info electSelf 1`, `{"repl_event":"election_start"}`},
+		{`election succeeded, assuming primary role in term 6`, `{"repl_event":"election_won","term":6}`},
+		{`replSet election succeeded, assuming primary role`, `{"repl_event":"election_won"}`},
+		{`beginning rollback`, `{"repl_event":"rollback"}`},
+		{`rollback 0`, `{"repl_event":"rollback"}`},
+		{`rollback 2 FindCommonPoint`, `{}`},
+		{`waiting for connections on port 27017`, `{}`},
+	}
+	for i, c := range cases {
+		result := replFields(t, "2015-03-04T11:31:45.116-0800 I REPL     [ReplicationExecutor] "+c.input)
+		if result != c.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, c.expected, result)
+		}
+	}
+}
+
+func TestJSONReplMessages(t *testing.T) {
+	cases := []struct{ input, expected string }{
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"REPL","id":21358,"ctx":"ReplCoord-0","msg":"Replica set state transition","attr":{"newState":"PRIMARY","oldState":"SECONDARY"}}`,
+			`{"new_state":"PRIMARY","old_state":"SECONDARY","repl_event":"state_change"}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"REPL","id":21215,"ctx":"ReplCoord-1","msg":"Member is in new state","attr":{"hostAndPort":"db2.example.com:27017","newState":"SECONDARY"}}`,
+			`{"member":"db2.example.com:27017","new_state":"SECONDARY","repl_event":"member_state"}`,
+		},
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"ELECTION","id":21450,"ctx":"ReplCoord-2","msg":"Election succeeded, assuming primary role","attr":{"term":7}}`,
+			`{"repl_event":"election_won","term":7}`,
+		},
+	}
+	for i, c := range cases {
+		if result := replFields(t, c.input); result != c.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package logline
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// writeFlags are the flags of write operations, which are logged as flag:1, or on
+// their own by some versions.  They're reported as booleans.
+var writeFlags = map[string]bool{
+	"fastmod":       true,
+	"fastmodinsert": true,
+	"idhack":        true,
+	"upsert":        true,
+}
+
+// readBareFlag reads one of writeFlags logged without a value, returning "" and
+// leaving the position alone if there isn't one
+func (p *nonPegLogLineParser) readBareFlag() string {
+	end := p.position
+	for unicode.IsLetter(p.runes[end]) {
+		end++
+	}
+	flag := string(p.runes[p.position:end])
+	if !writeFlags[flag] || !unicode.IsSpace(p.runes[end]) {
+		return ""
+	}
+	p.position = end
+	return flag
+}
+
+// addWriteOpFields makes the write flags booleans, and adds the update_type and
+// update_operators fields to an update.  The update document is in the update field,
+// or in the command document's u field from 3.6 on.
+func addWriteOpFields(fields map[string]interface{}) {
+	for flag := range writeFlags {
+		if n, ok := fields[flag].(float64); ok {
+			fields[flag] = n != 0
+		}
+	}
+
+	if fields["operation"] != "update" {
+		return
+	}
+	update, ok := fields["update"]
+	command, _ := fields["command"].(map[string]interface{})
+	if !ok && command != nil {
+		update, ok = command["u"]
+		if upsert, isBool := command["upsert"].(bool); isBool {
+			if _, logged := fields["upsert"]; !logged {
+				fields["upsert"] = upsert
+			}
+		}
+	}
+	if !ok {
+		return
+	}
+
+	var updateType string
+	var operators []string
+	switch u := update.(type) {
+	case map[string]interface{}:
+		updateType = "replacement"
+		for key := range u {
+			if strings.HasPrefix(key, "$") {
+				updateType = "operator"
+				operators = append(operators, key)
+			}
+		}
+	case []interface{}:
+		// >= 4.2 takes an aggregation pipeline, whose stages are reported as the
+		// operators
+		updateType = "pipeline"
+		for _, stage := range u {
+			if doc, ok := stage.(map[string]interface{}); ok {
+				for key := range doc {
+					operators = append(operators, key)
+				}
+			}
+		}
+	default:
+		return
+	}
+	fields["update_type"] = updateType
+	if len(operators) != 0 {
+		sort.Strings(operators)
+		var list []interface{}
+		for i, op := range operators {
+			// a pipeline can use a stage more than once
+			if i == 0 || op != operators[i-1] {
+				list = append(list, op)
+			}
+		}
+		fields["update_operators"] = list
+	}
+}
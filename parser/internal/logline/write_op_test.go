@@ -0,0 +1,54 @@
+package logline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+func TestWriteOps(t *testing.T) {
+	// the fields compared, leaving out the timestamp, locks and so on
+	names := []string{"operation", "fastmod", "fastmodinsert", "idhack", "upsert", "nMatched", "nModified", "nupdated", "ndeleted", "keysExamined", "docsExamined", "update_type", "update_operators"}
+	cases := []struct{ input, expected string }{
+		// < 2.6
+		{
+			`Mon Feb 23 03:20:19.670 [conn5] update test.foo query: { _id: 1 } update: { $set: { a: 1 }, $inc: { n: 1 } } idhack:1 nupdated:1 fastmod:1 keyUpdates:0 locks(micros) w:120 0ms`,
+			`{"fastmod":true,"idhack":true,"nupdated":1,"operation":"update","update_operators":["$inc","$set"],"update_type":"operator"}`,
+		},
+		{
+			`Mon Feb 23 03:20:19.670 [conn5] update test.foo query: { _id: 1 } update: { _id: 1, a: 2 } idhack fastmodinsert upsert:1 nupdated:1 keyUpdates:0 locks(micros) w:120 0ms`,
+			`{"fastmodinsert":true,"idhack":true,"nupdated":1,"operation":"update","update_type":"replacement","upsert":true}`,
+		},
+		// >= 3.6 logs the update statement as the command
+		{
+			`2018-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo command: { q: { a: 1 }, u: [ { $set: { b: 1 } }, { $unset: "c" }, { $set: { d: 1 } } ], multi: false, upsert: true } planSummary: IXSCAN { a: 1 } keysExamined:1 docsExamined:1 nMatched:1 nModified:1 numYields:0 locks:{} 0ms`,
+			`{"docsExamined":1,"keysExamined":1,"nMatched":1,"nModified":1,"operation":"update","update_operators":["$set","$unset"],"update_type":"pipeline","upsert":true}`,
+		},
+		{
+			`2018-03-04T11:31:45.116-0800 I WRITE    [conn2] remove test.foo command: { q: { a: 1 }, limit: 0 } planSummary: COLLSCAN keysExamined:0 docsExamined:10 ndeleted:2 numYields:0 locks:{} 3ms`,
+			`{"docsExamined":10,"keysExamined":0,"ndeleted":2,"operation":"remove"}`,
+		},
+		// >= 4.4
+		{
+			`{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"WRITE","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"update","ns":"test.foo","command":{"q":{"a":1},"u":{"$push":{"b":1}},"multi":false,"upsert":false},"planSummary":"COLLSCAN","keysExamined":0,"docsExamined":10,"nMatched":1,"nModified":1,"durationMillis":105}}`,
+			`{"docsExamined":10,"keysExamined":0,"nMatched":1,"nModified":1,"operation":"update","update_operators":["$push"],"update_type":"operator","upsert":false}`,
+		},
+	}
+	for i, c := range cases {
+		fields, err := logline.ParseLogLine(c.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		got := make(map[string]interface{})
+		for _, name := range names {
+			if v, ok := fields[name]; ok {
+				got[name] = v
+			}
+		}
+		buf, _ := json.Marshal(got)
+		if string(buf) != c.expected {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, c.expected, buf)
+		}
+	}
+}
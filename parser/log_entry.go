@@ -0,0 +1,360 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+// Severity is the severity level of a log line
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityDebug
+	SeverityInformational
+	SeverityWarning
+	SeverityError
+	SeverityFatal
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:       "unknown",
+	SeverityDebug:         "debug",
+	SeverityInformational: "informational",
+	SeverityWarning:       "warning",
+	SeverityError:         "error",
+	SeverityFatal:         "fatal",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity returns the Severity for a name as used in the "severity" field
+func ParseSeverity(name string) Severity {
+	for s, n := range severityNames {
+		if n == name {
+			return s
+		}
+	}
+	return SeverityUnknown
+}
+
+// Namespace is a MongoDB namespace split into its database and collection
+type Namespace struct {
+	DB         string
+	Collection string
+}
+
+// ParseNamespace splits a namespace such as "test.foo.bar" at the first '.'
+func ParseNamespace(ns string) Namespace {
+	if i := strings.Index(ns, "."); i >= 0 {
+		return Namespace{DB: ns[:i], Collection: ns[i+1:]}
+	}
+	return Namespace{DB: ns}
+}
+
+func (n Namespace) String() string {
+	if n.Collection == "" {
+		return n.DB
+	}
+	return n.DB + "." + n.Collection
+}
+
+// LockStats are the statistics of one mode of a lock taken by an operation.  Lines
+// from before 3.0 only have LockedMicros, and later ones only the rest.
+type LockStats struct {
+	AcquireCount  int64
+	WaitCount     int64
+	WaitMicros    int64
+	DeadlockCount int64
+	// LockedMicros is the time the lock was held
+	LockedMicros int64
+}
+
+// ConnectionEvent is a client connection being accepted or ended
+type ConnectionEvent struct {
+	// Accepted is true for a new connection and false for one that ended
+	Accepted     bool
+	ClientIP     string
+	ClientPort   int
+	ConnectionID int64
+	// Open is the number of connections open after the event, or -1 if it isn't
+	// logged
+	Open int64
+}
+
+// ReplEvent is a replica set state change, election or rollback
+type ReplEvent struct {
+	// Type is state_change for the logging node changing state, member_state for it
+	// seeing another member change state, election_start, election_won or rollback
+	Type     string
+	OldState string
+	NewState string
+	// Member is the host and port of the other member, for member_state
+	Member string
+	// Term is the election term, or -1 if it isn't logged
+	Term int64
+}
+
+// LogEntry is a typed representation of a parsed MongoDB log line
+type LogEntry struct {
+	Timestamp time.Time
+	Severity  Severity
+	Component string
+	Context   string
+
+	// Message is set for lines that aren't operations
+	Message string
+	// Connection is set for messages about a client connection being accepted or
+	// ended
+	Connection *ConnectionEvent
+	// Repl is set for messages about replica set state changes, elections and
+	// rollbacks
+	Repl *ReplEvent
+
+	Operation   string
+	Namespace   Namespace
+	CommandType string
+	Duration    time.Duration
+
+	NToReturn  int64
+	NToSkip    int64
+	NReturned  int64
+	ResLen     int64
+	NumYields  int64
+	KeyUpdates int64
+	// NScanned is the number of index keys examined ("keysExamined" from 3.2 on)
+	NScanned int64
+	// NScannedObjects is the number of documents examined ("docsExamined" from 3.2 on)
+	NScannedObjects int64
+	WriteConflicts  int64
+
+	// the results and flags of updates, removes and inserts
+	NMatched  int64
+	NModified int64
+	NInserted int64
+	NDeleted  int64
+	Upsert    bool
+	FastMod   bool
+	IDHack    bool
+	// UpdateType is operator, replacement or pipeline for an update, and
+	// UpdateOperators the operators, such as $set and $inc, or pipeline stages it uses
+	UpdateType      string
+	UpdateOperators []string
+
+	// PlanSummary is the plan of an operation that logs one
+	PlanSummary *PlanSummary
+
+	// Locks are the lock statistics by resource, such as Global or Database, and then
+	// mode, such as r or W
+	Locks map[string]map[string]LockStats
+
+	Query   map[string]interface{}
+	Update  map[string]interface{}
+	Command map[string]interface{}
+
+	// QueryShape is the filter with its literal values replaced, SortShape the sort
+	// document, and QueryShapeHash a hash of both
+	QueryShape     string
+	SortShape      string
+	QueryShapeHash string
+
+	// Fields holds every field of the line as returned by ParseLogLine
+	Fields map[string]interface{}
+}
+
+// QueryPattern returns the query shape, followed by the sort shape if there is one,
+// which is what groups similar operations together
+func (e *LogEntry) QueryPattern() string {
+	if e.SortShape == "" {
+		return e.QueryShape
+	}
+	return e.QueryShape + " sort: " + e.SortShape
+}
+
+// ParseLogEntry parses a MongoDB log line into a LogEntry.  ctime timestamps are
+// taken to be in the current year, local time; use a TimestampParser to control that.
+func ParseLogEntry(input string) (*LogEntry, error) {
+	return (&TimestampParser{}).ParseLogEntry(input)
+}
+
+// ParseUndated is ParseLogEntry for lines parsed out of order, such as in parallel.
+// A timestamp that doesn't carry its year, which is inferred from the lines before,
+// is left for TimestampParser.Date to decode: until then the entry's Timestamp is
+// zero.
+func ParseUndated(input string) (*LogEntry, error) {
+	fields, order, err := logline.Parse(input)
+	if err != nil {
+		return nil, newParseError(0, input, err)
+	}
+	return newLogEntry(fields, order, nil)
+}
+
+// NewLogEntry builds a LogEntry from the fields returned by ParseLogLine.  The maps
+// don't keep the order of the fields of index key patterns, so those of the
+// PlanSummary are sorted; ParseLogEntry keeps them in order.
+func NewLogEntry(fields map[string]interface{}) (*LogEntry, error) {
+	return newLogEntry(fields, nil, &TimestampParser{})
+}
+
+// newLogEntry builds a LogEntry from the fields of a line and the order of the keys
+// of its documents, which may be nil.  With a nil tp the timestamp is only decoded
+// if it carries its year.
+func newLogEntry(fields map[string]interface{}, order logline.KeyOrder, tp *TimestampParser) (*LogEntry, error) {
+	e := &LogEntry{Fields: fields}
+
+	if ts, ok := fields["timestamp"].(string); ok {
+		if tp == nil {
+			e.Timestamp, _ = parseDated(ts)
+		} else {
+			var err error
+			if e.Timestamp, err = tp.Parse(ts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	e.Severity = ParseSeverity(stringField(fields, "severity"))
+	e.Component = stringField(fields, "component")
+	e.Context = stringField(fields, "context")
+	e.Message = stringField(fields, "message")
+	if event := stringField(fields, "connection_event"); event != "" {
+		e.Connection = &ConnectionEvent{
+			Accepted:     event == "accepted",
+			ClientIP:     stringField(fields, "client_ip"),
+			ClientPort:   int(intField(fields, "client_port")),
+			ConnectionID: intField(fields, "connection_id"),
+			Open:         -1,
+		}
+		if n, ok := numberField(fields, "connections_open"); ok {
+			e.Connection.Open = int64(n)
+		}
+	}
+	if event := stringField(fields, "repl_event"); event != "" {
+		e.Repl = &ReplEvent{
+			Type:     event,
+			OldState: stringField(fields, "old_state"),
+			NewState: stringField(fields, "new_state"),
+			Member:   stringField(fields, "member"),
+			Term:     -1,
+		}
+		if n, ok := numberField(fields, "term"); ok {
+			e.Repl.Term = int64(n)
+		}
+	}
+
+	e.Operation = stringField(fields, "operation")
+	e.Namespace = ParseNamespace(stringField(fields, "namespace"))
+	e.CommandType = stringField(fields, "command_type")
+	if ms, ok := numberField(fields, "duration"); ok {
+		e.Duration = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	e.NToReturn = intField(fields, "ntoreturn")
+	e.NToSkip = intField(fields, "ntoskip")
+	e.NReturned = intField(fields, "nreturned")
+	e.ResLen = intField(fields, "reslen")
+	e.NumYields = intField(fields, "numYields")
+	e.KeyUpdates = intField(fields, "keyUpdates")
+	e.NScanned = intField(fields, "nscanned", "keysExamined")
+	e.NScannedObjects = intField(fields, "nscannedObjects", "docsExamined")
+	e.WriteConflicts = intField(fields, "writeConflicts")
+
+	e.NMatched = intField(fields, "nMatched")
+	e.NModified = intField(fields, "nModified", "nupdated")
+	e.NInserted = intField(fields, "ninserted", "nInserted")
+	e.NDeleted = intField(fields, "ndeleted", "nDeleted")
+	e.Upsert, _ = fields["upsert"].(bool)
+	e.FastMod, _ = fields["fastmod"].(bool)
+	e.IDHack, _ = fields["idhack"].(bool)
+	e.UpdateType = stringField(fields, "update_type")
+	if operators, ok := fields["update_operators"].([]interface{}); ok {
+		for _, op := range operators {
+			if s, ok := op.(string); ok {
+				e.UpdateOperators = append(e.UpdateOperators, s)
+			}
+		}
+	}
+
+	if stages, ok := fields["planSummary"].([]interface{}); ok {
+		ps := newPlanSummary(stages, order)
+		ps.FromMultiPlanner = boolField(fields, "fromMultiPlanner")
+		ps.Replanned = boolField(fields, "replanned")
+		ps.HasSort = ps.HasSort || boolField(fields, "hasSortStage") || boolField(fields, "scanAndOrder")
+		e.PlanSummary = ps
+	}
+
+	if locks, ok := fields["locks"].(map[string]interface{}); ok {
+		e.Locks = make(map[string]map[string]LockStats, len(locks))
+		for resource, value := range locks {
+			modes, _ := value.(map[string]interface{})
+			e.Locks[resource] = make(map[string]LockStats, len(modes))
+			for mode, value := range modes {
+				stats, _ := value.(map[string]interface{})
+				e.Locks[resource][mode] = LockStats{
+					AcquireCount:  intField(stats, "acquire_count"),
+					WaitCount:     intField(stats, "wait_count"),
+					WaitMicros:    intField(stats, "wait_micros"),
+					DeadlockCount: intField(stats, "deadlock_count"),
+					LockedMicros:  intField(stats, "locked_micros"),
+				}
+			}
+		}
+	}
+
+	e.Query, _ = fields["query"].(map[string]interface{})
+	e.Update, _ = fields["update"].(map[string]interface{})
+	e.Command, _ = fields["command"].(map[string]interface{})
+
+	e.QueryShape = stringField(fields, "query_shape")
+	e.SortShape = stringField(fields, "sort_shape")
+	e.QueryShapeHash = stringField(fields, "query_shape_hash")
+
+	return e, nil
+}
+
+func stringField(fields map[string]interface{}, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
+
+func numberField(fields map[string]interface{}, name string) (float64, bool) {
+	switch n := fields[name].(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// boolField returns whether a flag logged as true or as 1 is set
+func boolField(fields map[string]interface{}, name string) bool {
+	if b, ok := fields[name].(bool); ok {
+		return b
+	}
+	n, ok := numberField(fields, name)
+	return ok && n != 0
+}
+
+// intField returns the first of the named fields that is present as an int64
+func intField(fields map[string]interface{}, names ...string) int64 {
+	for _, name := range names {
+		if n, ok := numberField(fields, name); ok {
+			return int64(n)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package parser_test
+
+import (
+	"fmt"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func ExampleParseLogEntry() {
+	line := "2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1 } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:10 keyUpdates:0 writeConflicts:0 numYields:0 nreturned:1 reslen:40 locks:{} 102ms"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Println(entry.Timestamp.UTC())
+	fmt.Println(entry.Severity, entry.Component, entry.Context)
+	fmt.Println(entry.Operation, entry.Namespace.DB, entry.Namespace.Collection, entry.Duration)
+	fmt.Println(entry.NScannedObjects, entry.NReturned, entry.Query)
+	// output:
+	// 2015-03-04 19:31:45.116 +0000 UTC
+	// informational QUERY conn2
+	// query test foo 102ms
+	// 10 1 map[a:1]
+}
+
+func ExampleLogEntry_connection() {
+	line := "2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] connection accepted from 10.0.0.5:53422 #1234 (56 connections now open)"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Printf("%+v\n", *entry.Connection)
+	// output:
+	// {Accepted:true ClientIP:10.0.0.5 ClientPort:53422 ConnectionID:1234 Open:56}
+}
+
+func ExampleLogEntry_repl() {
+	line := "2015-03-04T11:31:45.116-0800 I REPL     [ReplicationExecutor] election succeeded, assuming primary role in term 6"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Printf("%+v\n", *entry.Repl)
+	// output:
+	// {Type:election_won OldState: NewState: Member: Term:6}
+}
+
+func ExampleLogEntry_update() {
+	line := "2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: 1 } update: { $set: { a: 1 }, $inc: { n: 1 } } nscanned:1 nscannedObjects:1 nMatched:1 nModified:1 fastmod:1 keyUpdates:0 writeConflicts:0 numYields:0 locks:{} 0ms"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Println(entry.NMatched, entry.NModified, entry.FastMod, entry.Upsert)
+	fmt.Println(entry.UpdateType, entry.UpdateOperators)
+	// output:
+	// 1 1 true false
+	// operator [$inc $set]
+}
+
+func ExampleLogEntry_locks() {
+	line := "2015-03-04T11:31:45.116-0800 I WRITE    [conn2] update test.foo query: { _id: 1 } update: { $set: { a: 1 } } nMatched:1 nModified:1 numYields:0 locks:{ Global: { acquireCount: { w: 2 } }, Database: { acquireCount: { w: 2 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 3021 } } } 4ms"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Printf("%+v\n", entry.Locks["Database"]["w"])
+	// output:
+	// {AcquireCount:2 WaitCount:1 WaitMicros:3021 DeadlockCount:0 LockedMicros:0}
+}
+
+func ExampleLogEntry_planSummary() {
+	line := `{"t":{"$date":"2020-05-20T19:18:40.604+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.foo","command":{"find":"foo","filter":{"b":5,"a":{"$gt":5}},"$db":"test"},"planSummary":"IXSCAN { b: 1, a: -1 }","fromMultiPlanner":true,"keysExamined":10,"docsExamined":10,"nreturned":10,"reslen":1234,"durationMillis":105}}`
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Println(entry.PlanSummary.IsCollectionScan(), entry.PlanSummary.FromMultiPlanner, entry.PlanSummary.IndexesUsed())
+	// output:
+	// false true [{ b: 1, a: -1 }]
+}
+
+func ExampleLogEntry_QueryPattern() {
+	line := "2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { query: { status: \"A\" }, orderby: { created: -1 } } planSummary: COLLSCAN ntoreturn:0 ntoskip:0 nscanned:0 nscannedObjects:1000 nreturned:2 reslen:40 locks:{} 100ms"
+	entry, _ := parser.ParseLogEntry(line)
+	fmt.Println(entry.QueryPattern())
+	// output:
+	// {"status":1} sort: {"created":-1}
+}
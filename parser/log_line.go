@@ -2,7 +2,17 @@ package parser
 
 import "github.com/toshok/mongologtools/parser/internal/logline"
 
-// ParseLogLine attempts to parse a MongoDB log line into a structured representation
+// ObjectID is the type of the values logged as ObjectId('...') in the parsed fields.
+// It holds the hex string, and marshals to JSON as one.
+type ObjectID = logline.ObjectID
+
+// ParseLogLine attempts to parse a MongoDB log line into a structured representation.
+// Both the text format and the JSON format used by MongoDB >= 4.4 are supported.
+// Lines that can't be parsed return a *ParseError.
 func ParseLogLine(input string) (map[string]interface{}, error) {
-	return logline.ParseLogLine(input)
+	fields, err := logline.ParseLogLine(input)
+	if err != nil {
+		return nil, newParseError(0, input, err)
+	}
+	return fields, nil
 }
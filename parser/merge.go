@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"container/heap"
+	"io"
+)
+
+// Merger merges the lines of several Scanners, such as one per member of a replica
+// set, into a single stream in timestamp order.  Each input must itself be in time
+// order.  Only the next line of each input is held in memory.
+type Merger struct {
+	scanners []*Scanner
+	heads    mergeHeap
+	// refill is the inputs whose next line is yet to be read
+	refill []int
+}
+
+// NewMerger returns a Merger reading from scanners
+func NewMerger(scanners ...*Scanner) *Merger {
+	m := &Merger{scanners: scanners}
+	for i := range scanners {
+		m.refill = append(m.refill, i)
+	}
+	return m
+}
+
+// Next returns the line with the earliest timestamp among the inputs, and the index
+// of the input it came from.  Lines with equal timestamps come in the order of their
+// inputs.  A *ParseError is returned as soon as it's read, after which merging can
+// continue, and io.EOF once every input is exhausted.  Any other error is from an
+// input's reader.
+func (m *Merger) Next() (*LogEntry, int, error) {
+	for len(m.refill) != 0 {
+		i := m.refill[0]
+		entry, err := m.scanners[i].Next()
+		if err == io.EOF {
+			m.refill = m.refill[1:]
+			continue
+		}
+		if err != nil {
+			// the input is read again on the next call
+			return nil, i, err
+		}
+		m.refill = m.refill[1:]
+		heap.Push(&m.heads, mergeHead{entry, i})
+	}
+	if len(m.heads) == 0 {
+		return nil, -1, io.EOF
+	}
+	head := heap.Pop(&m.heads).(mergeHead)
+	m.refill = append(m.refill, head.input)
+	return head.entry, head.input, nil
+}
+
+// mergeHead is the next line of an input
+type mergeHead struct {
+	entry *LogEntry
+	input int
+}
+
+// mergeHeap is a container/heap of the inputs' next lines, earliest first
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	ti, tj := h[i].entry.Timestamp, h[j].entry.Timestamp
+	if ti.Equal(tj) {
+		return h[i].input < h[j].input
+	}
+	return ti.Before(tj)
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeHead)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	head := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return head
+}
@@ -0,0 +1,58 @@
+package parser_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestMerger(t *testing.T) {
+	inputs := []string{
+		strings.Join([]string{
+			`2015-03-04T11:31:45.100Z I REPL     [rsSync] a1`,
+			`2015-03-04T11:31:45.300Z I REPL     [rsSync] a3`,
+			`2015-03-04T11:31:45.300Z I REPL     [rsSync] a4`,
+		}, "\n"),
+		strings.Join([]string{
+			`2015-03-04T11:31:45.200Z I REPL     [rsSync] b2`,
+			`garbage line`,
+			`2015-03-04T11:31:45.300Z I REPL     [rsSync] b3`,
+			`2015-03-04T11:31:45.500Z I REPL     [rsSync] b5`,
+		}, "\n"),
+		``,
+		// a different timestamp format sorts by the time it stands for
+		`2015-03-04T06:31:45.400-0500 I REPL     [rsSync] c4`,
+	}
+	var scanners []*parser.Scanner
+	for _, input := range inputs {
+		scanners = append(scanners, parser.NewScanner(strings.NewReader(input)))
+	}
+
+	m := parser.NewMerger(scanners...)
+	var got []string
+	for {
+		entry, i, err := m.Next()
+		if err == io.EOF {
+			break
+		}
+		if perr, ok := err.(*parser.ParseError); ok {
+			if i != 1 || perr.Line != 2 {
+				t.Errorf("unexpected error from input %d: %v", i, perr)
+			}
+			got = append(got, "error")
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, entry.Message)
+	}
+
+	// the parse error is read when b2 is replaced as the head of its input
+	expected := "a1 b2 error a3 a4 b3 c4 b5"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("expected '%s'\nbut got '%s'", expected, strings.Join(got, " "))
+	}
+}
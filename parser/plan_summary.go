@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+// IndexKey is one field of an index key pattern.  Value is 1 or -1 for an ascending
+// or descending field, or the index type, such as "text", "2dsphere" or "hashed".
+type IndexKey struct {
+	Field string
+	Value interface{}
+}
+
+// KeyPattern is an index key pattern, with its fields in order
+type KeyPattern []IndexKey
+
+// String formats the key pattern the way it's logged, as in { a: 1, b: -1 }
+func (k KeyPattern) String() string {
+	parts := make([]string, len(k))
+	for i, key := range k {
+		switch v := key.Value.(type) {
+		case float64:
+			parts[i] = key.Field + ": " + strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			parts[i] = fmt.Sprintf("%s: %q", key.Field, v)
+		}
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// PlanStage is one stage of a plan summary, such as IXSCAN { a: 1 }
+type PlanStage struct {
+	Name string
+	// KeyPattern is the index key pattern of a stage that uses an index, such as
+	// IXSCAN, COUNT_SCAN or DISTINCT_SCAN, and nil otherwise
+	KeyPattern KeyPattern
+}
+
+// PlanSummary is the planSummary of an operation: the stages that read documents
+// or index keys, such as "IXSCAN { a: 1 }, IXSCAN { b: 1 }" for an $or
+type PlanSummary struct {
+	Stages []PlanStage
+	// FromMultiPlanner is true when the plan was chosen from several candidates, and
+	// Replanned when it was chosen again because the cached plan did poorly
+	FromMultiPlanner bool
+	Replanned        bool
+	// HasSort is true when the results were sorted in memory rather than read in
+	// order from an index
+	HasSort bool
+}
+
+// ParsePlanSummary parses the text of a planSummary, such as "IXSCAN { a: 1, b: -1 }"
+func ParsePlanSummary(s string) (*PlanSummary, error) {
+	stages, order, err := logline.ParsePlanSummary(s)
+	if err != nil {
+		return nil, err
+	}
+	return newPlanSummary(stages, order), nil
+}
+
+// newPlanSummary builds a PlanSummary from the value of the planSummary field, a list
+// of { STAGE: <key pattern or true> } documents.  Without the key order from the
+// parse, the fields of the key patterns are sorted.
+func newPlanSummary(stages []interface{}, order logline.KeyOrder) *PlanSummary {
+	ps := &PlanSummary{}
+	for i, elem := range stages {
+		doc, _ := elem.(map[string]interface{})
+		for name, value := range doc {
+			stage := PlanStage{Name: name}
+			if pattern, ok := value.(map[string]interface{}); ok {
+				stage.KeyPattern = KeyPattern{}
+				for _, field := range order.Keys(fmt.Sprintf("planSummary.%d.%s", i, name), pattern) {
+					stage.KeyPattern = append(stage.KeyPattern, IndexKey{Field: field, Value: pattern[field]})
+				}
+			}
+			if stage.Name == "SORT" {
+				ps.HasSort = true
+			}
+			ps.Stages = append(ps.Stages, stage)
+		}
+	}
+	return ps
+}
+
+// IsCollectionScan returns true if any stage of the plan is a COLLSCAN
+func (ps *PlanSummary) IsCollectionScan() bool {
+	return ps.hasStage("COLLSCAN")
+}
+
+// IsIDHack returns true if the plan is the fast path for lookups by _id
+func (ps *PlanSummary) IsIDHack() bool {
+	return ps.hasStage("IDHACK")
+}
+
+func (ps *PlanSummary) hasStage(name string) bool {
+	for _, stage := range ps.Stages {
+		if stage.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexesUsed returns the key patterns of the indexes the plan uses, each once, in
+// the order they appear
+func (ps *PlanSummary) IndexesUsed() []KeyPattern {
+	var rv []KeyPattern
+	seen := make(map[string]bool)
+	for _, stage := range ps.Stages {
+		if stage.KeyPattern == nil {
+			continue
+		}
+		s := stage.KeyPattern.String()
+		if !seen[s] {
+			seen[s] = true
+			rv = append(rv, stage.KeyPattern)
+		}
+	}
+	return rv
+}
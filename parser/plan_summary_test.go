@@ -0,0 +1,72 @@
+package parser_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestParsePlanSummary(t *testing.T) {
+	cases := []struct {
+		input    string
+		stages   string
+		collscan bool
+		idhack   bool
+		sort     bool
+		indexes  string
+	}{
+		{`COLLSCAN`, `[COLLSCAN]`, true, false, false, `[]`},
+		{`IDHACK`, `[IDHACK]`, false, true, false, `[]`},
+		{`IXSCAN { b: 1, a: -1 }`, `[IXSCAN]`, false, false, false, `[{ b: 1, a: -1 }]`},
+		{`IXSCAN { a: 1 }, IXSCAN { "b.c": 1, d: "text" }, IXSCAN { a: 1 }`, `[IXSCAN IXSCAN IXSCAN]`, false, false, false, `[{ a: 1 } { b.c: 1, d: "text" }]`},
+		{`SORT, COLLSCAN`, `[SORT COLLSCAN]`, true, false, true, `[]`},
+		{`COUNT_SCAN { loc: "2dsphere" }`, `[COUNT_SCAN]`, false, false, false, `[{ loc: "2dsphere" }]`},
+	}
+	for i, c := range cases {
+		ps, err := parser.ParsePlanSummary(c.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		var stages []string
+		for _, stage := range ps.Stages {
+			stages = append(stages, stage.Name)
+		}
+		if s := fmt.Sprint(stages); s != c.stages {
+			t.Errorf("case %d: expected stages %s, got %s", i, c.stages, s)
+		}
+		if ps.IsCollectionScan() != c.collscan || ps.IsIDHack() != c.idhack || ps.HasSort != c.sort {
+			t.Errorf("case %d: unexpected flags %+v", i, ps)
+		}
+		if s := fmt.Sprint(ps.IndexesUsed()); s != c.indexes {
+			t.Errorf("case %d: expected indexes %s, got %s", i, c.indexes, s)
+		}
+	}
+
+	if _, err := parser.ParsePlanSummary(`IXSCAN { a 1 }`); err == nil {
+		t.Errorf("expected an error for an invalid key pattern")
+	}
+}
+
+func TestLogEntryPlanSummary(t *testing.T) {
+	line := `2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1, b: 2 } planSummary: IXSCAN { b: 1, a: -1 }, IXSCAN { z: 1, c: 1 } ntoreturn:0 ntoskip:0 nscanned:1 nscannedObjects:1 nreturned:1 reslen:40 locks:{} 1ms`
+	entry, err := parser.ParseLogEntry(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(entry.PlanSummary.IndexesUsed()); s != `[{ b: 1, a: -1 } { z: 1, c: 1 }]` {
+		t.Errorf("expected the key patterns in order, got %s", s)
+	}
+	if _, ok := entry.Fields["plan_summary_text"]; ok {
+		t.Errorf("unexpected plan_summary_text field")
+	}
+
+	// without the key order the fields are sorted
+	entry, err = parser.NewLogEntry(entry.Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(entry.PlanSummary.IndexesUsed()); s != `[{ a: -1, b: 1 } { c: 1, z: 1 }]` {
+		t.Errorf("expected sorted key patterns, got %s", s)
+	}
+}
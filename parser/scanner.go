@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+// maxLineSize is the longest log line a Scanner accepts
+const maxLineSize = 16 * 1024 * 1024
+
+// ParseError describes a log line that couldn't be parsed
+type ParseError struct {
+	// Line is the 1-based line number within the input, or 0 when parsing a
+	// single line with ParseLogLine
+	Line int
+	// Offset is the rune offset into the line where parsing failed
+	Offset int
+	// Expected describes what the parser was looking for at Offset, if known
+	Expected string
+	// Text is the original line
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+	if e.Expected != "" {
+		msg += " (expected " + e.Expected + ")"
+	}
+	if e.Line != 0 {
+		msg = fmt.Sprintf("line %d, %s", e.Line, msg)
+	}
+	return msg
+}
+
+// newParseError wraps an error from the log line parser
+func newParseError(line int, text string, err error) *ParseError {
+	perr := &ParseError{Line: line, Text: text, Err: err}
+	if lerr, ok := err.(*logline.ParseError); ok {
+		perr.Offset = lerr.Offset
+		perr.Expected = lerr.Expected
+	}
+	return perr
+}
+
+// Scanner reads log lines from an io.Reader and parses them one at a time
+//
+//	s := parser.NewScanner(r)
+//	for {
+//		entry, err := s.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if perr, ok := err.(*parser.ParseError); ok {
+//			// skip, quarantine or abort
+//			continue
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+type Scanner struct {
+	// Timestamps decodes the timestamps of the scanned lines.  Set its Year and
+	// Location before the first call to Next for logs with ctime timestamps.
+	Timestamps TimestampParser
+
+	s      *bufio.Scanner
+	line   int
+	offset int64
+}
+
+// NewScanner returns a Scanner reading from r
+func NewScanner(r io.Reader) *Scanner {
+	s := &Scanner{s: bufio.NewScanner(r)}
+	s.s.Buffer(nil, maxLineSize)
+	s.s.Split(s.scanLines)
+	return s
+}
+
+// scanLines is bufio.ScanLines, counting the bytes of the lines for Offset
+func (s *Scanner) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	s.offset += int64(advance)
+	return advance, token, err
+}
+
+// Next parses the next line.  It returns a *ParseError for a line that can't be
+// parsed, after which scanning can continue, and io.EOF at the end of the input.
+// Any other error is from the underlying reader.
+func (s *Scanner) Next() (*LogEntry, error) {
+	if !s.s.Scan() {
+		if err := s.s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	s.line++
+
+	text := s.s.Text()
+	fields, order, err := logline.Parse(text)
+	if err != nil {
+		return nil, newParseError(s.line, text, err)
+	}
+	entry, err := newLogEntry(fields, order, &s.Timestamps)
+	if err != nil {
+		return nil, &ParseError{Line: s.line, Expected: "timestamp", Text: text, Err: err}
+	}
+	return entry, nil
+}
+
+// Line returns the line number of the line last returned by Next
+func (s *Scanner) Line() int {
+	return s.line
+}
+
+// Offset returns the offset in the input just past the line last returned by Next,
+// including its newline
+func (s *Scanner) Offset() int64 {
+	return s.offset
+}
@@ -0,0 +1,62 @@
+package parser_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestScanner(t *testing.T) {
+	input := strings.Join([]string{
+		`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1 } 102ms`,
+		`2015-03-04T11:31:45.116-0800 X QUERY    [conn2] query test.foo query: { a: 1 } 102ms`,
+		`2015-03-04T11:31:45.116-0800 I QUERY    [conn2] query test.foo query: { a: 1 ] 102ms`,
+		`2015-03-04T11:31:45.116-0800 I NETWORK  [initandlisten] waiting for connections on port 27017`,
+	}, "\n")
+
+	s := parser.NewScanner(strings.NewReader(input))
+	var entries []*parser.LogEntry
+	var errs []*parser.ParseError
+	var offsets []int64
+	for {
+		entry, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		offsets = append(offsets, s.Offset())
+		if perr, ok := err.(*parser.ParseError); ok {
+			errs = append(errs, perr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 || entries[0].Operation != "query" || entries[1].Message == "" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	// each offset is just past the newline of the line returned, and the last line
+	// has none
+	if len(offsets) != 4 || offsets[0] != int64(strings.Index(input, "\n")+1) || offsets[3] != int64(len(input)) {
+		t.Errorf("unexpected offsets %v for %d bytes", offsets, len(input))
+	}
+	expected := []parser.ParseError{
+		{Line: 2, Offset: 29, Expected: "severity"},
+		{Line: 3, Offset: 77, Expected: "'}' or ','"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d", len(expected), len(errs))
+	}
+	for i, e := range expected {
+		if errs[i].Line != e.Line || errs[i].Offset != e.Offset || errs[i].Expected != e.Expected {
+			t.Errorf("error %d: expected line %d offset %d expecting %s, got %v", i, e.Line, e.Offset, e.Expected, errs[i])
+		}
+		if errs[i].Text != strings.Split(input, "\n")[e.Line-1] {
+			t.Errorf("error %d: unexpected text '%s'", i, errs[i].Text)
+		}
+	}
+}
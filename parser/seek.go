@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// SeekTime binary searches r, a log of size bytes in time order, for the offset of
+// the first line with a timestamp at or after t.  It returns size if there's none.
+// Lines that don't parse or have no timestamp are passed over.
+//
+// tp gives the Year and Location of ctime timestamps, and may be nil.  Each line
+// looked at is decoded on its own, so a log with ctime timestamps that runs into a
+// new year can't be searched.
+func SeekTime(r io.ReaderAt, size int64, t time.Time, tp *TimestampParser) (int64, error) {
+	if tp == nil {
+		tp = &TimestampParser{}
+	}
+	// find the smallest offset at which the next line with a timestamp isn't
+	// before t
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, ts, ok, err := nextTimestamp(r, size, mid, tp)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || !ts.Before(t) {
+			hi = mid
+		} else {
+			// every offset up to the start of that line finds it too
+			lo = start + 1
+		}
+	}
+	start, _, ok, err := nextTimestamp(r, size, lo, tp)
+	if err != nil || !ok {
+		return size, err
+	}
+	return start, nil
+}
+
+// nextTimestamp finds the first line starting at or after off that has a timestamp,
+// returning its offset and timestamp
+func nextTimestamp(r io.ReaderAt, size, off int64, tp *TimestampParser) (int64, time.Time, bool, error) {
+	start, err := lineStart(r, size, off)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	br := bufio.NewReader(io.NewSectionReader(r, start, size-start))
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, time.Time{}, false, err
+		}
+		if line == "" {
+			return 0, time.Time{}, false, nil
+		}
+		if fields, perr := ParseLogLine(strings.TrimRight(line, "\r\n")); perr == nil {
+			if s, ok := fields["timestamp"].(string); ok {
+				// a fresh parser, since the lines aren't looked at in order
+				probe := TimestampParser{Year: tp.Year, Location: tp.Location}
+				if ts, terr := probe.Parse(s); terr == nil {
+					return start, ts, true, nil
+				}
+			}
+		}
+		start += int64(len(line))
+	}
+}
+
+// lineStart returns the offset of the first line starting at or after off
+func lineStart(r io.ReaderAt, size, off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	// off is a line start if the byte before it ends a line
+	buf := make([]byte, 4096)
+	for pos := off - 1; pos < size; {
+		n, err := r.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(n)
+	}
+	return size, nil
+}
@@ -0,0 +1,52 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toshok/mongologtools/parser"
+)
+
+func TestSeekTime(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("2015-03-04T11:%02d:%02d.000Z I NETWORK  [conn%d] end connection 127.0.0.1:%d", i/60, i%60, i, 50000+i))
+		if i%7 == 0 {
+			lines = append(lines, "  a line that doesn't parse")
+		}
+	}
+	input := strings.Join(lines, "\n") + "\n"
+	r := strings.NewReader(input)
+	size := int64(len(input))
+
+	cases := []struct {
+		at       time.Time
+		expected string
+	}{
+		// before the first line
+		{time.Date(2015, time.March, 4, 10, 0, 0, 0, time.UTC), lines[0]},
+		{time.Date(2015, time.March, 4, 11, 0, 0, 0, time.UTC), lines[0]},
+		{time.Date(2015, time.March, 4, 11, 1, 40, 0, time.UTC), "2015-03-04T11:01:40.000Z"},
+		// between two lines
+		{time.Date(2015, time.March, 4, 11, 1, 40, 500, time.UTC), "2015-03-04T11:01:41.000Z"},
+		// just after a line that doesn't parse
+		{time.Date(2015, time.March, 4, 11, 1, 11, 0, time.UTC), "2015-03-04T11:01:11.000Z"},
+		{time.Date(2015, time.March, 4, 11, 3, 19, 0, time.UTC), "2015-03-04T11:03:19.000Z"},
+		// after the last line
+		{time.Date(2015, time.March, 4, 11, 3, 20, 0, time.UTC), ""},
+	}
+	for i, c := range cases {
+		off, err := parser.SeekTime(r, size, c.at, nil)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if !strings.HasPrefix(input[off:], c.expected) || (c.expected == "" && off != size) {
+			t.Errorf("case %d: expected a line starting '%s'\nbut got '%.40s'", i, c.expected, input[off:])
+		}
+		if off != 0 && input[off-1] != '\n' {
+			t.Errorf("case %d: offset %d isn't the start of a line", i, off)
+		}
+	}
+}
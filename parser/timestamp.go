@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/toshok/mongologtools/parser/internal/logline"
+)
+
+// layouts for the --timeStampFormat variants mongod writes, plus the >= 4.4 JSON form
+var (
+	isoLayouts = []string{
+		"2006-01-02T15:04:05.000Z0700",  // iso8601-local, iso8601-utc
+		"2006-01-02T15:04:05.000Z07:00", // >= 4.4 JSON
+	}
+	ctimeLayouts = []string{
+		"Mon Jan _2 15:04:05.000", // ctime
+		"Mon Jan _2 15:04:05",     // ctime-no-ms
+	}
+)
+
+// TimestampParser decodes the timestamps extracted by ParseLogLine into time.Time.
+//
+// The ctime and ctime-no-ms formats carry neither a year nor a zone, so they're
+// taken to be in Year and Location.  A TimestampParser remembers the last ctime
+// timestamp it decoded and moves on to the next year when the log crosses Dec 31,
+// so a single TimestampParser should be used per log file.  The day of the week
+// they do carry has to match the year: a timestamp such as "Thu Feb 29" that isn't
+// a Thursday in the year it's placed in is an error.
+type TimestampParser struct {
+	// Year is the year of the first ctime timestamp.  Zero means the latest year,
+	// up to now, in which its date falls on its day of the week.
+	Year int
+	// Location is the zone ctime timestamps are in.  nil means time.Local.
+	Location *time.Location
+
+	last time.Time
+}
+
+// Parse decodes a single timestamp
+func (tp *TimestampParser) Parse(s string) (time.Time, error) {
+	if t, ok := parseDated(s); ok {
+		return t, nil
+	}
+	for _, layout := range ctimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return tp.inferYear(t, s)
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp '%s'", s)
+}
+
+// parseDated decodes the timestamps that carry their year, which don't depend on
+// the lines before them
+func parseDated(s string) (time.Time, bool) {
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	// canonical extended JSON dates are milliseconds since the epoch
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)), true
+	}
+	return time.Time{}, false
+}
+
+// ParseLogEntry is like the package level ParseLogEntry, but decodes the
+// timestamp with tp.
+func (tp *TimestampParser) ParseLogEntry(input string) (*LogEntry, error) {
+	fields, order, err := logline.Parse(input)
+	if err != nil {
+		return nil, newParseError(0, input, err)
+	}
+	return newLogEntry(fields, order, tp)
+}
+
+// NewLogEntry is like the package level NewLogEntry, but decodes the timestamp
+// with tp.
+func (tp *TimestampParser) NewLogEntry(fields map[string]interface{}) (*LogEntry, error) {
+	return newLogEntry(fields, nil, tp)
+}
+
+// Date decodes the timestamp of an entry from ParseUndated that was left undecoded,
+// and does nothing for the others.  The entries of a log must be dated in order.
+func (tp *TimestampParser) Date(e *LogEntry) error {
+	ts, ok := e.Fields["timestamp"].(string)
+	if !ok || !e.Timestamp.IsZero() {
+		return nil
+	}
+	var err error
+	e.Timestamp, err = tp.Parse(ts)
+	return err
+}
+
+// inferYear places a timestamp s, parsed without a year (so in year 0, UTC) as t,
+// into the year and zone tp is tracking.  time.Parse doesn't check the day of the
+// week, so it's checked here.
+func (tp *TimestampParser) inferYear(t time.Time, s string) (time.Time, error) {
+	loc := tp.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	weekday, ok := ctimeWeekday(s)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unrecognized day of the week in timestamp '%s'", s)
+	}
+	inYear := func(year int) (time.Time, bool) {
+		d := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		// time.Date moves a Feb 29 outside a leap year into March
+		return d, d.Day() == t.Day() && d.Weekday() == weekday
+	}
+
+	var year int
+	switch {
+	case !tp.last.IsZero():
+		year = tp.last.Year()
+		// a month more than half a year before the previous line's means we
+		// went past the end of the year
+		if tp.last.Month()-t.Month() > 6 {
+			year++
+		}
+	case tp.Year != 0:
+		year = tp.Year
+	default:
+		// the latest year that fits, not counting dates still to come; the days of
+		// the week repeat every 400 years
+		now := time.Now().In(loc)
+		for year = now.Year(); year > now.Year()-400; year-- {
+			if d, ok := inYear(year); ok && !d.After(now.AddDate(0, 0, 1)) {
+				tp.last = d
+				return d, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("no year in which timestamp '%s' falls on a %s", s, weekday)
+	}
+	d, ok := inYear(year)
+	if !ok {
+		return time.Time{}, fmt.Errorf("timestamp '%s' isn't on a %s in %d", s, weekday, year)
+	}
+	tp.last = d
+	return d, nil
+}
+
+// ctimeWeekday returns the day of the week a ctime timestamp starts with
+func ctimeWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if len(s) >= 3 && s[:3] == d.String()[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampParser(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	cases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2014-06-02T11:31:45.116-0400", time.Date(2014, time.June, 2, 15, 31, 45, 116000000, time.UTC)},
+		{"2014-06-02T11:31:45.116Z", time.Date(2014, time.June, 2, 11, 31, 45, 116000000, time.UTC)},
+		{"2020-05-20T19:18:40.604+00:00", time.Date(2020, time.May, 20, 19, 18, 40, 604000000, time.UTC)},
+		{"1590002320604", time.Date(2020, time.May, 20, 19, 18, 40, 604000000, time.UTC)},
+		// ctime timestamps follow each other through a year boundary
+		{"Sat Dec 27 03:20:19.670", time.Date(2014, time.December, 27, 3, 20, 19, 670000000, est)},
+		{"Wed Dec 31 23:59:59", time.Date(2014, time.December, 31, 23, 59, 59, 0, est)},
+		{"Thu Jan  1 00:00:01.002", time.Date(2015, time.January, 1, 0, 0, 1, 2000000, est)},
+		{"Mon Feb 23 03:20:19.670", time.Date(2015, time.February, 23, 3, 20, 19, 670000000, est)},
+	}
+	tp := &TimestampParser{Year: 2014, Location: est}
+	for i, testcase := range cases {
+		result, err := tp.Parse(testcase.input)
+		if err != nil {
+			t.Fatalf("case %d: error parsing: %v", i, err)
+		}
+		if !result.Equal(testcase.expected) {
+			t.Errorf("case %d: expected '%s'\nbut got '%s'", i, testcase.expected, result)
+		}
+	}
+
+	if _, err := tp.Parse("yesterday"); err == nil {
+		t.Errorf("expected an error for an unrecognized timestamp")
+	}
+}
+
+func TestTimestampParserWeekday(t *testing.T) {
+	// Feb 29 2015 doesn't exist, so it isn't moved to Mar 1
+	tp := &TimestampParser{Year: 2015, Location: time.UTC}
+	if ts, err := tp.Parse("Sun Feb 29 12:00:00.000"); err == nil {
+		t.Errorf("expected an error for Feb 29 2015, got %s", ts)
+	}
+	// nor is a day that's on another day of the week in the year
+	tp = &TimestampParser{Year: 2015, Location: time.UTC}
+	if ts, err := tp.Parse("Tue Feb 23 03:20:19.670"); err == nil {
+		t.Errorf("expected an error for a Tuesday Feb 23 2015, got %s", ts)
+	}
+
+	// without a Year the day of the week picks it
+	tp = &TimestampParser{Location: time.UTC}
+	ts, err := tp.Parse("Thu Feb 29 12:00:00.000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Month() != time.February || ts.Day() != 29 || ts.Weekday() != time.Thursday || ts.After(time.Now()) {
+		t.Errorf("expected the latest Thursday Feb 29, got %s", ts)
+	}
+	next, err := tp.Parse("Fri Mar  1 00:00:01")
+	if err != nil || next.Year() != ts.Year() || next.YearDay() != ts.YearDay()+1 {
+		t.Errorf("expected the day after %s, got %s (%v)", ts, next, err)
+	}
+}
+
+func TestParseUndated(t *testing.T) {
+	lines := []string{
+		"Wed Dec 31 23:59:59.000 [conn1] query test.foo query: { b: 1, a: 1 } planSummary: IXSCAN { b: 1, a: 1 } ntoreturn:0 nreturned:1 reslen:40 102ms",
+		"Thu Jan  1 00:00:01.002 [conn1] end connection 127.0.0.1:50000",
+		"2015-01-01T00:00:02.000Z I NETWORK  [conn2] end connection 127.0.0.1:50001",
+	}
+	// parsed out of order, but dated in order
+	entries := make([]*LogEntry, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		entry, err := ParseUndated(lines[i])
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		entries[i] = entry
+	}
+	if !entries[0].Timestamp.IsZero() || !entries[1].Timestamp.IsZero() || entries[2].Timestamp.IsZero() {
+		t.Errorf("expected only the ctime timestamps to be left undecoded")
+	}
+	if indexes := entries[0].PlanSummary.IndexesUsed(); len(indexes) != 1 || indexes[0].String() != "{ b: 1, a: 1 }" {
+		t.Errorf("expected the index key order to be kept, got %v", indexes)
+	}
+
+	tp := &TimestampParser{Year: 2014, Location: time.UTC}
+	expected := []time.Time{
+		time.Date(2014, time.December, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2015, time.January, 1, 0, 0, 1, 2000000, time.UTC),
+		time.Date(2015, time.January, 1, 0, 0, 2, 0, time.UTC),
+	}
+	for i, entry := range entries {
+		if err := tp.Date(entry); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if !entry.Timestamp.Equal(expected[i]) {
+			t.Errorf("line %d: expected '%s'\nbut got '%s'", i, expected[i], entry.Timestamp)
+		}
+	}
+}